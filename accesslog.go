@@ -0,0 +1,62 @@
+package main
+
+import (
+	"math/rand"
+	"net/http"
+	"os"
+
+	"github.com/datasektionen/taitan/redact"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	accessLog       *log.Logger // nil if access logging is off.
+	accessLogSample float64     // Fraction of successful requests to log.
+)
+
+// setupAccessLog sets up the access log in format, "json", "logfmt" or "off".
+func setupAccessLog(format string, sample float64) {
+	accessLogSample = sample
+	switch format {
+	case "off":
+		accessLog = nil
+		return
+	case "json":
+		accessLog = log.New()
+		accessLog.SetFormatter(&log.JSONFormatter{})
+	default:
+		accessLog = log.New()
+		accessLog.SetFormatter(&log.TextFormatter{DisableColors: true, FullTimestamp: true})
+	}
+	accessLog.SetOutput(os.Stdout)
+	accessLog.AddHook(redact.Hook{})
+}
+
+// logAccess writes one line to the access log for a request. Requests that
+// failed are always logged, successful ones are sampled.
+func logAccess(rec *recorder, req *http.Request) {
+	if accessLog == nil {
+		return
+	}
+	if rec.status < 400 && accessLogSample < 1 && rand.Float64() >= accessLogSample {
+		return
+	}
+
+	fields := log.Fields{
+		"method":      req.Method,
+		"host":        req.Host,
+		"path":        req.URL.Path,
+		"route":       rec.route,
+		"status":      rec.status,
+		"bytes":       rec.bytes,
+		"duration_ms": float64(rec.duration.Microseconds()) / 1000,
+		"request_id":  rec.requestID,
+	}
+	if rec.lang != "" {
+		fields["lang"] = rec.lang
+	}
+	if location := rec.Header().Get("Location"); location != "" {
+		fields["redirect"] = location
+	}
+	accessLog.WithFields(fields).Info("request")
+}
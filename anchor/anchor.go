@@ -1,6 +1,7 @@
 package anchor
 
 import (
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -14,6 +15,7 @@ type Anchor struct {
 	ID          string `json:"id"`    // Id of the h* element.
 	Value       string `json:"value"` // Value inside the anchor tag.
 	HeaderLevel int    `json:"level"` // Level of the h* element.
+	HTML        string `json:"html"`  // Value with inline formatting, see inline.
 }
 
 // Anchors finds <h*> elements inside a HTML string to create a list of anchors.
@@ -36,6 +38,7 @@ func Anchors(body string) (anchs []Anchor, err error) {
 					ID:          id,
 					Value:       val,
 					HeaderLevel: headerLevel,
+					HTML:        inline(n),
 				})
 			}
 		}
@@ -56,15 +59,54 @@ func findIDAttr(attrs []html.Attribute) string {
 	return ""
 }
 
-// Find plain text value of a HTML tag.
+// plain returns the text of a HTML node with whitespace normalized.
 func plain(n *html.Node) string {
-	if n.Type == html.TextNode {
-		return n.Data
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
 	}
-	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		return plain(c)
+	walk(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// inlineTags are the elements kept by inline.
+var inlineTags = map[string]bool{
+	"b": true, "code": true, "del": true, "em": true, "i": true, "kbd": true,
+	"mark": true, "s": true, "strong": true, "sub": true, "sup": true,
+}
+
+// space matches runs of whitespace.
+var space = regexp.MustCompile(`\s+`)
+
+// inline returns the content of a HTML node as HTML with whitespace
+// normalized, keeping only the inline formatting elements in inlineTags
+// without attributes. Other elements, such as links, are replaced by their
+// content.
+func inline(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			switch {
+			case c.Type == html.TextNode:
+				b.WriteString(html.EscapeString(c.Data))
+			case c.Type == html.ElementNode && inlineTags[c.Data]:
+				b.WriteString("<" + c.Data + ">")
+				walk(c)
+				b.WriteString("</" + c.Data + ">")
+			default:
+				walk(c)
+			}
+		}
 	}
-	return ""
+	walk(n)
+	return strings.TrimSpace(space.ReplaceAllString(b.String(), " "))
 }
 
 func isHNode(n *html.Node) bool {
@@ -81,3 +123,35 @@ func isHNode(n *html.Node) bool {
 
 	return false
 }
+
+// TOCEntry is a heading in a table of contents, with the headings below it.
+type TOCEntry struct {
+	Anchor
+	Children []*TOCEntry `json:"children,omitempty"`
+}
+
+// TOC nests the anchors with levels from minLevel to maxLevel into a table
+// of contents, where each heading is below the closest preceding heading of
+// a higher level. Skipped levels are not filled in, so an <h3> directly
+// after an <h1> is a child of it.
+func TOC(anchs []Anchor, minLevel, maxLevel int) []*TOCEntry {
+	toc := make([]*TOCEntry, 0)
+	var stack []*TOCEntry
+	for _, a := range anchs {
+		if a.HeaderLevel < minLevel || a.HeaderLevel > maxLevel {
+			continue
+		}
+		for len(stack) > 0 && stack[len(stack)-1].HeaderLevel >= a.HeaderLevel {
+			stack = stack[:len(stack)-1]
+		}
+		e := &TOCEntry{Anchor: a}
+		if len(stack) == 0 {
+			toc = append(toc, e)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, e)
+		}
+		stack = append(stack, e)
+	}
+	return toc
+}
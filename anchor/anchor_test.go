@@ -3,7 +3,9 @@ package anchor
 import (
 	"bytes"
 	"log"
+	"reflect"
 	"slices"
+	"strings"
 	"testing"
 
 	"golang.org/x/net/html"
@@ -16,6 +18,9 @@ var plaintests = []struct {
 	{s2html("<b><b><b>plain</b></b></b>"), "plain"},
 	{s2html("<b><b></b></b>"), ""},
 	{s2html("asdf"), "asdf"},
+	{s2html("<h2>Foo <em>bar</em> baz</h2>"), "Foo bar baz"},
+	{s2html("<h2><a>x</a> y</h2>"), "x y"},
+	{s2html("<h2>\n  spaced \n <code>out</code>\n</h2>"), "spaced out"},
 }
 
 func s2html(s string) *html.Node {
@@ -40,10 +45,11 @@ var anchortests = []struct {
 	in  string
 	out []Anchor
 }{
-	{`<h1 id="asdf"><b><b>plain</b></b></h1>`, []Anchor{{"asdf", "plain", 1}}},
+	{`<h1 id="asdf"><b><b>plain</b></b></h1>`, []Anchor{{"asdf", "plain", 1, "<b><b>plain</b></b>"}}},
 	{`<b><b></b></b>`, []Anchor{}},
 	{`asdf`, []Anchor{}},
-	{`<h2 id="bing"><span style="color: red;">chilling</h2>`, []Anchor{{"bing", "chilling", 2}}},
+	{`<h2 id="bing"><span style="color: red;">chilling</h2>`, []Anchor{{"bing", "chilling", 2, "chilling"}}},
+	{`<h2 id="foo">Foo <em>bar</em> baz</h2>`, []Anchor{{"foo", "Foo bar baz", 2, "Foo <em>bar</em> baz"}}},
 }
 
 func TestAnchors(t *testing.T) {
@@ -58,6 +64,25 @@ func TestAnchors(t *testing.T) {
 	}
 }
 
+var inlinetests = []struct {
+	in  *html.Node
+	out string
+}{
+	{s2html("<h2>Foo <em>bar</em> baz</h2>"), "Foo <em>bar</em> baz"},
+	{s2html(`<h2><a href="/x">x</a> <code class="go">y &lt; z</code></h2>`), "x <code>y &lt; z</code>"},
+	{s2html(`<h2><strong onclick="alert(1)">a</strong><img src="x" onerror="alert(1)"></h2>`), "<strong>a</strong>"},
+	{s2html("<h2>\n  a  \n b </h2>"), "a b"},
+}
+
+func TestInline(t *testing.T) {
+	for _, tt := range inlinetests {
+		got := inline(tt.in)
+		if tt.out != got {
+			t.Errorf("inline(%#v) => %q, want %q", tt.in, got, tt.out)
+		}
+	}
+}
+
 var findattrtests = []struct {
 	in  []html.Attribute
 	out string
@@ -75,3 +100,69 @@ func TestFindIDAttr(t *testing.T) {
 		}
 	}
 }
+
+// tocString writes toc as e.g. "a(b c(d)) e".
+func tocString(toc []*TOCEntry) string {
+	var s []string
+	for _, e := range toc {
+		if len(e.Children) > 0 {
+			s = append(s, e.ID+"("+tocString(e.Children)+")")
+		} else {
+			s = append(s, e.ID)
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+var toctests = []struct {
+	in       []Anchor
+	min, max int
+	out      string
+}{
+	{[]Anchor{}, 1, 6, ""},
+	{[]Anchor{{"a", "", 1, ""}, {"b", "", 2, ""}, {"c", "", 2, ""}, {"d", "", 1, ""}}, 1, 6, "a(b c) d"},
+	{[]Anchor{{"a", "", 1, ""}, {"b", "", 3, ""}, {"c", "", 2, ""}, {"d", "", 3, ""}}, 1, 6, "a(b c(d))"},
+	{[]Anchor{{"a", "", 3, ""}, {"b", "", 2, ""}, {"c", "", 1, ""}, {"d", "", 2, ""}}, 1, 6, "a b c(d)"},
+	{[]Anchor{{"a", "", 1, ""}, {"b", "", 2, ""}, {"c", "", 3, ""}, {"d", "", 2, ""}}, 2, 2, "b d"},
+	{[]Anchor{{"a", "", 1, ""}, {"b", "", 2, ""}, {"c", "", 3, ""}, {"d", "", 4, ""}}, 2, 3, "b(c)"},
+}
+
+func TestTOC(t *testing.T) {
+	for _, tt := range toctests {
+		got := tocString(TOC(tt.in, tt.min, tt.max))
+		if got != tt.out {
+			t.Errorf("TOC(%v, %d, %d) => %q, want %q", tt.in, tt.min, tt.max, got, tt.out)
+		}
+	}
+}
+
+var setidstests = []struct {
+	in, out []string
+}{
+	{[]string{"<h1>Id test</h1>\n"}, []string{"<h1 id=\"id-test\">Id test</h1>\n"}},
+	{[]string{"<h2>Nämnder och kassörer</h2>"}, []string{`<h2 id="namnder-och-kassorer">Nämnder och kassörer</h2>`}},
+	{[]string{"<h2>Kontakt</h2><h2>Kontakt</h2><h2>Kontakt</h2>"}, []string{`<h2 id="kontakt">Kontakt</h2><h2 id="kontakt-2">Kontakt</h2><h2 id="kontakt-3">Kontakt</h2>`}},
+	{[]string{"<h2>Kontakt {#styrelsen}</h2>"}, []string{`<h2 id="styrelsen">Kontakt</h2>`}},
+	{[]string{"<h2>Kontakt <em>oss</em> {#oss}</h2>"}, []string{`<h2 id="oss">Kontakt <em>oss</em></h2>`}},
+	{[]string{"<h2>Kontakt</h2><h3>Kontakt {#kontakt}</h3>"}, []string{`<h2 id="kontakt-2">Kontakt</h2><h3 id="kontakt">Kontakt</h3>`}},
+	{[]string{`<h2 id="keep">Kept</h2>`}, []string{`<h2 id="keep">Kept</h2>`}},
+	{[]string{"<h2>Vad är C++ & Go?</h2>"}, []string{`<h2 id="vad-ar-c-go">Vad är C++ &amp; Go?</h2>`}},
+	{[]string{"<h2>!!!</h2>"}, []string{`<h2 id="section">!!!</h2>`}},
+	{[]string{"<p>No headings</p>"}, []string{"<p>No headings</p>"}},
+	{[]string{"<h2>Kontakt</h2>", "<h2>Kontakt</h2>"}, []string{`<h2 id="kontakt">Kontakt</h2>`, `<h2 id="kontakt-2">Kontakt</h2>`}},
+	{[]string{"<h2>Kontakt</h2>", "<h2>Kontakt {#kontakt}</h2>"}, []string{`<h2 id="kontakt-2">Kontakt</h2>`, `<h2 id="kontakt">Kontakt</h2>`}},
+	{[]string{`<p><a id="styrelsen"></a></p><h2>Styrelsen</h2>`}, []string{`<p><a id="styrelsen"></a></p><h2 id="styrelsen-2">Styrelsen</h2>`}},
+	{[]string{"<h2>A {#x}</h2><h2>B {#x}</h2>"}, []string{`<h2 id="x">A</h2><h2 id="x-2">B</h2>`}},
+}
+
+func TestSetIDs(t *testing.T) {
+	for _, tt := range setidstests {
+		got, err := SetIDs(tt.in...)
+		if err != nil {
+			t.Errorf("SetIDs(%q) returned error %q", tt.in, err)
+		}
+		if !reflect.DeepEqual(got, tt.out) {
+			t.Errorf("SetIDs(%q) => %q, want %q", tt.in, got, tt.out)
+		}
+	}
+}
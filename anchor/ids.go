@@ -0,0 +1,143 @@
+package anchor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/datasektionen/taitan/fold"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// customID matches an explicit id at the end of a heading: "Kontakt {#kontakt-styrelsen}".
+var customID = regexp.MustCompile(`\s*\{#([\w:.-]+)\}\s*$`)
+
+// transliterations are the letters that aren't letters with diacritics, and
+// so aren't folded by fold.String.
+var transliterations = strings.NewReplacer("æ", "ae", "Æ", "ae", "ø", "o", "Ø", "o", "ß", "ss")
+
+// SetIDs gives every heading in the HTML bodies of a page, such as its body
+// and sidebar, an id and returns the resulting HTML. The ids are unique
+// across the bodies, and don't take ids that other elements already have.
+// Headings that already have an id, or end with an explicit "{#id}", keep it
+// unless an element before them has it. Other headings get their text in
+// lower case, with å, ä and ö as a and o and anything but letters and digits
+// as dashes. Headings with the same id get the suffixes -2, -3 and so on, in
+// order, so that the ids only change when the heading or one with the same
+// text before it does.
+func SetIDs(bodies ...string) ([]string, error) {
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	parsed := make([][]*html.Node, len(bodies))
+	used := make(map[string]bool)
+	var headings []*html.Node
+	var find func(*html.Node)
+	find = func(n *html.Node) {
+		if isHNode(n) {
+			headings = append(headings, n)
+			return
+		}
+		if n.Type == html.ElementNode {
+			if id := findIDAttr(n.Attr); id != "" {
+				used[id] = true
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	for i, body := range bodies {
+		nodes, err := html.ParseFragment(strings.NewReader(body), context)
+		if err != nil {
+			return nil, err
+		}
+		parsed[i] = nodes
+		for _, n := range nodes {
+			find(n)
+		}
+	}
+
+	// Explicit ids go first, so that generated ids don't take them.
+	ids := make([]string, len(headings))
+	for i, h := range headings {
+		ids[i] = findIDAttr(h.Attr)
+		if id := takeCustomID(h); id != "" {
+			ids[i] = id
+		}
+		if ids[i] != "" {
+			ids[i] = unique(ids[i], used)
+		}
+	}
+	for i, h := range headings {
+		if ids[i] == "" {
+			ids[i] = unique(slug(plain(h)), used)
+		}
+		setIDAttr(h, ids[i])
+	}
+
+	out := make([]string, len(parsed))
+	for i, nodes := range parsed {
+		var b strings.Builder
+		for _, n := range nodes {
+			if err := html.Render(&b, n); err != nil {
+				return nil, err
+			}
+		}
+		out[i] = b.String()
+	}
+	return out, nil
+}
+
+// unique returns base, or base with the first suffix -2, -3 and so on that
+// makes it unused, and marks it as used.
+func unique(base string, used map[string]bool) string {
+	id := base
+	for n := 2; used[id]; n++ {
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	used[id] = true
+	return id
+}
+
+// takeCustomID removes an explicit id from the end of the heading n and
+// returns it, or "" if it has none.
+func takeCustomID(n *html.Node) string {
+	last := n.LastChild
+	for last != nil && last.Type == html.ElementNode {
+		last = last.LastChild
+	}
+	if last == nil || last.Type != html.TextNode {
+		return ""
+	}
+	m := customID.FindStringSubmatchIndex(last.Data)
+	if m == nil {
+		return ""
+	}
+	id := last.Data[m[2]:m[3]]
+	last.Data = last.Data[:m[0]]
+	return id
+}
+
+// slug returns the generated id of a heading with the text s.
+func slug(s string) string {
+	s = fold.String(transliterations.Replace(s))
+	id := strings.Join(strings.FieldsFunc(s, func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	}), "-")
+	if id == "" {
+		return "section"
+	}
+	return id
+}
+
+func setIDAttr(n *html.Node, id string) {
+	for i, attr := range n.Attr {
+		if attr.Key == "id" {
+			n.Attr[i].Val = id
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: "id", Val: id})
+}
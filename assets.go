@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/datasektionen/taitan/highlight"
+	"github.com/datasektionen/taitan/pages"
+)
+
+// assetsPath is the path that the files of the content are served under,
+// inside the mount.
+const assetsPath = "/_assets"
+
+// assetCacheControl is the Cache-Control header of assets, which only change
+// with taitan itself.
+const assetCacheControl = "public, max-age=86400"
+
+// highlightCSSHandler serves the stylesheet of highlighted code in the chroma
+// style given by the style parameter, highlight.DefaultStyle by default.
+func highlightCSSHandler(res http.ResponseWriter, req *http.Request) {
+	style := req.URL.Query().Get("style")
+	if style == "" {
+		style = highlight.DefaultStyle
+	}
+	css, ok := highlight.CSS(style)
+	if !ok {
+		writeError(res, http.StatusNotFound, apiError{
+			Code:    codeNotFound,
+			Message: "Style does not exist",
+			Path:    req.URL.Path,
+		})
+		return
+	}
+
+	tag := etag(string(css))
+	res.Header().Set("ETag", tag)
+	res.Header().Set("Cache-Control", assetCacheControl)
+	if inm := req.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, tag) {
+		res.WriteHeader(http.StatusNotModified)
+		return
+	}
+	res.Header().Set("Content-Type", "text/css; charset=utf-8")
+	res.Write(css)
+}
+
+// assetHandler serves the file at the path p in the content of m, if it is
+// an asset of a page that is served. Files of sensitive pages are thus not
+// served during the reception.
+func assetHandler(res http.ResponseWriter, req *http.Request, m *mount, p string) {
+	p = path.Clean("/" + p)
+	responses := &m.responses
+	responses.Lock()
+	_, served := responses.Resps[path.Dir(p)]
+	tag := etag(responses.Revision, assetsPath, p)
+	responses.Unlock()
+
+	notFound := func() {
+		writeError(res, http.StatusNotFound, apiError{
+			Code:    codeNotFound,
+			Message: "File does not exist",
+			Path:    assetsPath + p,
+		})
+	}
+	if !served || !pages.IsAsset(p) {
+		notFound()
+		return
+	}
+	f, err := os.Open(filepath.Join(m.root(), filepath.FromSlash(p)))
+	if err != nil {
+		notFound()
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		notFound()
+		return
+	}
+
+	// The files are the authors', so don't let them run scripts, e.g. in
+	// SVG, on our origin.
+	res.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	res.Header().Set("X-Content-Type-Options", "nosniff")
+	if m.notModified(res, req, tag, fi.ModTime()) {
+		return
+	}
+	http.ServeContent(res, req, fi.Name(), time.Time{}, f)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// build identifies the running build of taitan, since a new build may render
+// the same content differently.
+var build = buildID()
+
+// buildID returns a hash of the executable, or the current time if it can't
+// be read.
+func buildID() string {
+	if exe, err := os.Executable(); err == nil {
+		if f, err := os.Open(exe); err == nil {
+			defer f.Close()
+			h := sha256.New()
+			if _, err := io.Copy(h, f); err == nil {
+				return hex.EncodeToString(h.Sum(nil)[:8])
+			}
+		}
+	}
+	return time.Now().Format(time.RFC3339Nano)
+}
+
+// revisionOf returns the revision of content loaded from commit, or at the
+// current time if the commit is unknown. Darkmode changes the content
+// without changing the commit, so it is part of the revision.
+func revisionOf(commit string, isReception bool) string {
+	if commit == "" {
+		commit = time.Now().Format(time.RFC3339Nano)
+	}
+	if isReception {
+		return commit + "-reception"
+	}
+	return commit
+}
+
+// etag returns a strong entity tag for a response derived from the build,
+// the snapshot revision and parts, such as the page and language.
+func etag(revision string, parts ...string) string {
+	h := sha256.New()
+	h.Write([]byte(build))
+	h.Write([]byte{0})
+	h.Write([]byte(revision))
+	for _, p := range parts {
+		h.Write([]byte{0})
+		h.Write([]byte(p))
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// codingTag returns the entity tag of the response with tag in the content
+// coding, since a strong tag must change with the bytes of the response.
+func codingTag(tag, coding string) string {
+	if coding == "identity" {
+		return tag
+	}
+	return strings.TrimSuffix(tag, `"`) + "-" + coding + `"`
+}
+
+// notModified sets the caching headers of a successful response with tag
+// and modified, which may be zero if unknown. It reports whether the client
+// already has the response, in which case 304 Not Modified has been written.
+func (m *mount) notModified(res http.ResponseWriter, req *http.Request, tag string, modified time.Time) bool {
+	res.Header().Set("ETag", tag)
+	res.Header().Set("Cache-Control", m.cacheControl)
+	if !modified.IsZero() {
+		res.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since.
+	fresh := false
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		fresh = etagMatches(inm, tag)
+	} else if ims := req.Header.Get("If-Modified-Since"); ims != "" && !modified.IsZero() {
+		t, err := http.ParseTime(ims)
+		fresh = err == nil && !modified.Truncate(time.Second).After(t)
+	}
+	if fresh {
+		res.WriteHeader(http.StatusNotModified)
+	}
+	return fresh
+}
+
+// etagMatches reports whether tag is in the If-None-Match header value inm,
+// using the weak comparison.
+func etagMatches(inm, tag string) bool {
+	for _, t := range strings.Split(inm, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var etagmatchestests = []struct {
+	inm, tag string
+	out      bool
+}{
+	{`"abc"`, `"abc"`, true},
+	{`"abd"`, `"abc"`, false},
+	{`W/"abc"`, `"abc"`, true},
+	{`"x", "abc"`, `"abc"`, true},
+	{`"x","y"`, `"abc"`, false},
+	{`*`, `"abc"`, true},
+	{`"abc-gzip"`, `"abc-gzip"`, true},
+	{`"abc"`, `"abc-gzip"`, false},
+	{`"abc-gzip"`, `"abc-br"`, false},
+}
+
+func TestETagMatches(t *testing.T) {
+	for _, tt := range etagmatchestests {
+		got := etagMatches(tt.inm, tt.tag)
+		if got != tt.out {
+			t.Errorf("etagMatches(%q, %q) => %v, want %v", tt.inm, tt.tag, got, tt.out)
+		}
+	}
+}
+
+var codingtagtests = []struct {
+	tag, coding, out string
+}{
+	{`"abc"`, "identity", `"abc"`},
+	{`"abc"`, "gzip", `"abc-gzip"`},
+	{`"abc"`, "br", `"abc-br"`},
+}
+
+func TestCodingTag(t *testing.T) {
+	for _, tt := range codingtagtests {
+		got := codingTag(tt.tag, tt.coding)
+		if got != tt.out {
+			t.Errorf("codingTag(%q, %q) => %q, want %q", tt.tag, tt.coding, got, tt.out)
+		}
+	}
+}
+
+var notmodifiedtests = []struct {
+	method  string
+	headers map[string]string
+	out     bool
+}{
+	{http.MethodGet, nil, false},
+	{http.MethodGet, map[string]string{"If-None-Match": `"abc"`}, true},
+	{http.MethodHead, map[string]string{"If-None-Match": `"x", "abc"`}, true},
+	{http.MethodGet, map[string]string{"If-None-Match": `"x"`}, false},
+	{http.MethodGet, map[string]string{"If-None-Match": `*`}, true},
+	{http.MethodPost, map[string]string{"If-None-Match": `"abc"`}, false},
+	{http.MethodGet, map[string]string{"If-Modified-Since": "Sun, 08 Nov 2015 23:14:30 GMT"}, true},
+	{http.MethodGet, map[string]string{"If-Modified-Since": "Sun, 08 Nov 2015 23:14:29 GMT"}, false},
+	// If-None-Match takes precedence.
+	{http.MethodGet, map[string]string{"If-None-Match": `"x"`, "If-Modified-Since": "Sun, 08 Nov 2015 23:14:30 GMT"}, false},
+}
+
+func TestNotModified(t *testing.T) {
+	m := &mount{cacheControl: "no-cache"}
+	modified := time.Date(2015, 11, 8, 23, 14, 30, 0, time.UTC)
+	for _, tt := range notmodifiedtests {
+		req := httptest.NewRequest(tt.method, "/", nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		res := httptest.NewRecorder()
+		got := m.notModified(res, req, `"abc"`, modified)
+		if got != tt.out {
+			t.Errorf("notModified(%s with %v) => %v, want %v", tt.method, tt.headers, got, tt.out)
+		}
+		if got && res.Code != http.StatusNotModified {
+			t.Errorf("notModified(%s with %v) wrote %d, want %d", tt.method, tt.headers, res.Code, http.StatusNotModified)
+		}
+		if res.Header().Get("ETag") != `"abc"` || res.Header().Get("Cache-Control") != "no-cache" {
+			t.Errorf("notModified(%s with %v) => headers %v", tt.method, tt.headers, res.Header())
+		}
+	}
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/datasektionen/taitan/redact"
+)
+
+// Config is the configuration of taitan, read from a TOML file and the
+// environment.
+//
+// The mount settings at the top level are defaults for all mounts. If the
+// file has no [[mount]] tables, a single mount is served using them.
+type Config struct {
+	Port            int           `toml:"port"`
+	LogLevel        string        `toml:"log_level"` // "debug", "info" or "warn".
+	Watch           bool          `toml:"watch"`
+	ReadTimeout     duration      `toml:"read_timeout"`      // Max time to read a request.
+	WriteTimeout    duration      `toml:"write_timeout"`     // Max time to handle a request and write the response.
+	IdleTimeout     duration      `toml:"idle_timeout"`      // Max time to keep idle connections open.
+	ShutdownTimeout duration      `toml:"shutdown_timeout"`  // Max time to drain connections and reloads on shutdown.
+	ReadyTolerance  duration      `toml:"ready_tolerance"`   // How long reloads may fail before we are not ready.
+	AccessLog       string        `toml:"access_log"`        // "logfmt", "json" or "off".
+	AccessLogSample float64       `toml:"access_log_sample"` // Fraction of successful requests to log.
+	MountConfig                   // Defaults for all mounts.
+	Mounts          []MountConfig `toml:"mount"`
+}
+
+// duration is a time.Duration written like "30s" in the configuration.
+type duration struct {
+	time.Duration
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *duration) UnmarshalText(text []byte) error {
+	var err error
+	d.Duration, err = time.ParseDuration(string(text))
+	return err
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// MountConfig is the configuration of a single mount.
+type MountConfig struct {
+	Name              string   `toml:"name,omitempty"`
+	Prefix            string   `toml:"prefix,omitempty"`
+	Hosts             []string `toml:"hosts,omitempty"`
+	ContentURL        string   `toml:"content_url,omitempty"`
+	ContentDir        string   `toml:"content_dir,omitempty"`
+	Ref               string   `toml:"ref,omitempty"`
+	DefaultLang       *string  `toml:"default_lang,omitempty"` // "" is a valid language.
+	DarkmodeURL       string   `toml:"darkmode_url,omitempty"`
+	Jumpfile          string   `toml:"jumpfile,omitempty"`
+	CORSOrigins       []string `toml:"cors_origins,omitempty"`
+	CORSMethods       []string `toml:"cors_methods,omitempty"`
+	CORSHeaders       []string `toml:"cors_headers,omitempty"`
+	CORSMaxAge        duration `toml:"cors_max_age,omitempty"`
+	FuzzyfileBaseURL  string   `toml:"fuzzyfile_base_url,omitempty"`
+	FuzzyfileAnchors  *int     `toml:"fuzzyfile_anchors,omitempty"` // 0 turns off anchors set at the top level.
+	CacheControl      string   `toml:"cache_control,omitempty"`
+	AssetBaseURL      string   `toml:"asset_base_url,omitempty"`
+	HTMLElements      []string `toml:"html_elements,omitempty"`   // Elements allowed besides the default ones.
+	HTMLAttributes    []string `toml:"html_attributes,omitempty"` // Attributes allowed besides the default ones.
+	TrustedDirs       []string `toml:"trusted_dirs,omitempty"`    // Directories whose HTML isn't sanitized.
+	Token             string   `toml:"token,omitempty"`
+	SSHKeyFile        string   `toml:"ssh_key_file,omitempty"`
+	SSHKnownHostsFile string   `toml:"ssh_known_hosts_file,omitempty"`
+}
+
+// envVars returns setters for the settings of mc that can be overridden by
+// environment variables, by variable name without mount prefix.
+func (mc *MountConfig) envVars() map[string]func(string) error {
+	str := func(s *string) func(string) error {
+		return func(v string) error { *s = v; return nil }
+	}
+	list := func(l *[]string) func(string) error {
+		return func(v string) error { *l = splitList(v); return nil }
+	}
+	integer := func(n **int) func(string) error {
+		return func(v string) error {
+			i, err := strconv.Atoi(v)
+			*n = &i
+			return err
+		}
+	}
+	return map[string]func(string) error{
+		"PREFIX":               str(&mc.Prefix),
+		"HOST":                 list(&mc.Hosts),
+		"CONTENT_URL":          str(&mc.ContentURL),
+		"CONTENT_DIR":          str(&mc.ContentDir),
+		"REF":                  str(&mc.Ref),
+		"DEFAULT_LANG":         func(v string) error { mc.DefaultLang = &v; return nil },
+		"DARKMODE_URL":         str(&mc.DarkmodeURL),
+		"JUMPFILE":             str(&mc.Jumpfile),
+		"CORS_ORIGINS":         list(&mc.CORSOrigins),
+		"CORS_METHODS":         list(&mc.CORSMethods),
+		"CORS_HEADERS":         list(&mc.CORSHeaders),
+		"CORS_MAX_AGE":         func(v string) error { return mc.CORSMaxAge.UnmarshalText([]byte(v)) },
+		"FUZZYFILE_BASE_URL":   str(&mc.FuzzyfileBaseURL),
+		"FUZZYFILE_ANCHORS":    integer(&mc.FuzzyfileAnchors),
+		"CACHE_CONTROL":        str(&mc.CacheControl),
+		"ASSET_BASE_URL":       str(&mc.AssetBaseURL),
+		"HTML_ELEMENTS":        list(&mc.HTMLElements),
+		"HTML_ATTRIBUTES":      list(&mc.HTMLAttributes),
+		"TRUSTED_DIRS":         list(&mc.TrustedDirs),
+		"TOKEN":                str(&mc.Token),
+		"SSH_KEY_FILE":         str(&mc.SSHKeyFile),
+		"SSH_KNOWN_HOSTS_FILE": str(&mc.SSHKnownHostsFile),
+	}
+}
+
+// applyEnv overrides the settings of mc with the environment variables
+// starting with prefix.
+func (mc *MountConfig) applyEnv(prefix string) error {
+	for key, set := range mc.envVars() {
+		if v, ok := os.LookupEnv(prefix + key); ok {
+			if err := set(v); err != nil {
+				return fmt.Errorf("$%s%s: %w", prefix, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// inherit sets all unset settings of mc to those of defaults.
+func (mc *MountConfig) inherit(defaults MountConfig) {
+	inheritString := func(s *string, d string) {
+		if *s == "" {
+			*s = d
+		}
+	}
+	inheritString(&mc.Prefix, defaults.Prefix)
+	inheritString(&mc.ContentURL, defaults.ContentURL)
+	inheritString(&mc.ContentDir, defaults.ContentDir)
+	inheritString(&mc.Ref, defaults.Ref)
+	inheritString(&mc.DarkmodeURL, defaults.DarkmodeURL)
+	inheritString(&mc.Jumpfile, defaults.Jumpfile)
+	inheritString(&mc.FuzzyfileBaseURL, defaults.FuzzyfileBaseURL)
+	inheritString(&mc.CacheControl, defaults.CacheControl)
+	inheritString(&mc.AssetBaseURL, defaults.AssetBaseURL)
+	inheritString(&mc.Token, defaults.Token)
+	inheritString(&mc.SSHKeyFile, defaults.SSHKeyFile)
+	inheritString(&mc.SSHKnownHostsFile, defaults.SSHKnownHostsFile)
+	if mc.Hosts == nil {
+		mc.Hosts = defaults.Hosts
+	}
+	if mc.CORSOrigins == nil {
+		mc.CORSOrigins = defaults.CORSOrigins
+	}
+	if mc.CORSMethods == nil {
+		mc.CORSMethods = defaults.CORSMethods
+	}
+	if mc.CORSHeaders == nil {
+		mc.CORSHeaders = defaults.CORSHeaders
+	}
+	if mc.HTMLElements == nil {
+		mc.HTMLElements = defaults.HTMLElements
+	}
+	if mc.HTMLAttributes == nil {
+		mc.HTMLAttributes = defaults.HTMLAttributes
+	}
+	if mc.TrustedDirs == nil {
+		mc.TrustedDirs = defaults.TrustedDirs
+	}
+	if mc.CORSMaxAge.Duration == 0 {
+		mc.CORSMaxAge = defaults.CORSMaxAge
+	}
+	if mc.DefaultLang == nil {
+		mc.DefaultLang = defaults.DefaultLang
+	}
+	if mc.FuzzyfileAnchors == nil {
+		mc.FuzzyfileAnchors = defaults.FuzzyfileAnchors
+	}
+}
+
+// setDefaults sets the unset settings of mc that have defaults, except those
+// that depend on the prefix of each mount, see setPrefixDefaults.
+func (mc *MountConfig) setDefaults() {
+	if mc.Jumpfile == "" {
+		mc.Jumpfile = "jumpfile.json"
+	}
+	if len(mc.CORSOrigins) == 0 {
+		mc.CORSOrigins = []string{"*"}
+	}
+	if len(mc.CORSMethods) == 0 {
+		mc.CORSMethods = []string{http.MethodGet, http.MethodHead}
+	}
+	if mc.CORSMaxAge.Duration == 0 {
+		mc.CORSMaxAge = duration{10 * time.Minute}
+	}
+	if mc.CacheControl == "" {
+		mc.CacheControl = "no-cache"
+	}
+	if mc.FuzzyfileAnchors == nil {
+		mc.FuzzyfileAnchors = new(int)
+	}
+}
+
+// setPrefixDefaults sets the unset settings of mc whose defaults depend on
+// its prefix: asset_base_url to the prefix, so that the URLs of assets are
+// relative to the host, and fuzzyfile_base_url to the prefix on
+// datasektionen.se.
+func (mc *MountConfig) setPrefixDefaults() {
+	prefix := "/" + strings.Trim(mc.Prefix, "/")
+	if mc.AssetBaseURL == "" {
+		mc.AssetBaseURL = prefix
+	}
+	if mc.FuzzyfileBaseURL == "" {
+		mc.FuzzyfileBaseURL = "https://datasektionen.se" + strings.TrimSuffix(prefix, "/")
+	}
+}
+
+// envPrefix returns the prefix of the environment variables of the mount
+// called name, e.g. STYRDOKUMENT_ for styrdokument.
+func envPrefix(name string) string {
+	return strings.ToUpper(strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' {
+			return '_'
+		}
+		return r
+	}, name)) + "_"
+}
+
+// loadConfig reads the configuration from the TOML file at path, if path is
+// not empty, and overrides it with the environment.
+//
+// Environment variables take precedence over the setting they correspond to:
+// STYRDOKUMENT_CONTENT_URL over content_url of the styrdokument mount, and
+// CONTENT_URL over the top level content_url. $MOUNTS selects which mounts
+// to serve.
+//
+// Unset settings get their defaults, so that the configuration is the one
+// used. A configuration that could be read but isn't valid is returned
+// together with what is wrong with it.
+func loadConfig(path string) (*Config, error) {
+	cfg := &Config{
+		LogLevel:        "warn",
+		ReadTimeout:     duration{10 * time.Second},
+		WriteTimeout:    duration{60 * time.Second},
+		IdleTimeout:     duration{120 * time.Second},
+		ShutdownTimeout: duration{25 * time.Second},
+		ReadyTolerance:  duration{10 * time.Minute},
+		AccessLog:       "logfmt",
+		AccessLogSample: 1,
+	}
+	if path != "" {
+		if _, err := toml.DecodeFile(path, cfg); err != nil {
+			return nil, fmt.Errorf("Could not read config file: %w", err)
+		}
+	}
+
+	if v, ok := os.LookupEnv("PORT"); ok {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("$PORT is not a number: %q", v)
+		}
+		cfg.Port = port
+	}
+	if v, ok := os.LookupEnv("ACCESS_LOG"); ok {
+		cfg.AccessLog = v
+	}
+	if v, ok := os.LookupEnv("ACCESS_LOG_SAMPLE"); ok {
+		sample, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("$ACCESS_LOG_SAMPLE is not a number: %q", v)
+		}
+		cfg.AccessLogSample = sample
+	}
+	for key, d := range map[string]*duration{
+		"READ_TIMEOUT":     &cfg.ReadTimeout,
+		"WRITE_TIMEOUT":    &cfg.WriteTimeout,
+		"IDLE_TIMEOUT":     &cfg.IdleTimeout,
+		"SHUTDOWN_TIMEOUT": &cfg.ShutdownTimeout,
+		"READY_TOLERANCE":  &cfg.ReadyTolerance,
+	} {
+		if v, ok := os.LookupEnv(key); ok {
+			if err := d.UnmarshalText([]byte(v)); err != nil {
+				return nil, fmt.Errorf("$%s: %w", key, err)
+			}
+		}
+	}
+	if err := cfg.MountConfig.applyEnv(""); err != nil {
+		return nil, err
+	}
+	cfg.MountConfig.setDefaults()
+	if len(cfg.Mounts) == 0 {
+		cfg.MountConfig.setPrefixDefaults()
+	}
+
+	if v, ok := os.LookupEnv("MOUNTS"); ok {
+		var mcs []MountConfig
+		for _, name := range splitList(v) {
+			mc := MountConfig{Name: name}
+			for _, c := range cfg.Mounts {
+				if c.Name == name {
+					mc = c
+				}
+			}
+			mcs = append(mcs, mc)
+		}
+		if len(mcs) == 0 {
+			return nil, errors.New("$MOUNTS does not name any mounts")
+		}
+		cfg.Mounts = mcs
+	}
+	for i := range cfg.Mounts {
+		if cfg.Mounts[i].Name != "" {
+			if err := cfg.Mounts[i].applyEnv(envPrefix(cfg.Mounts[i].Name)); err != nil {
+				return nil, err
+			}
+		}
+		cfg.Mounts[i].inherit(cfg.MountConfig)
+		cfg.Mounts[i].setDefaults()
+		cfg.Mounts[i].setPrefixDefaults()
+	}
+
+	return cfg, cfg.validate()
+}
+
+// mountConfigs returns the configurations of all mounts to serve.
+func (cfg *Config) mountConfigs() []MountConfig {
+	if len(cfg.Mounts) == 0 {
+		return []MountConfig{cfg.MountConfig}
+	}
+	return cfg.Mounts
+}
+
+// validate checks that cfg is complete, returning all problems found.
+func (cfg *Config) validate() error {
+	var errs []error
+	if cfg.Port <= 0 || cfg.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port: invalid port %d", cfg.Port))
+	}
+	for _, d := range []struct {
+		name string
+		d    duration
+	}{
+		{"read_timeout", cfg.ReadTimeout},
+		{"write_timeout", cfg.WriteTimeout},
+		{"idle_timeout", cfg.IdleTimeout},
+		{"shutdown_timeout", cfg.ShutdownTimeout},
+		{"ready_tolerance", cfg.ReadyTolerance},
+	} {
+		if d.d.Duration < 0 {
+			errs = append(errs, fmt.Errorf("%s: must not be negative", d.name))
+		}
+	}
+	switch cfg.AccessLog {
+	case "logfmt", "json", "off":
+	default:
+		errs = append(errs, fmt.Errorf("access_log: must be logfmt, json or off, not %q", cfg.AccessLog))
+	}
+	if cfg.AccessLogSample < 0 || cfg.AccessLogSample > 1 {
+		errs = append(errs, fmt.Errorf("access_log_sample: must be between 0 and 1, not %v", cfg.AccessLogSample))
+	}
+	switch cfg.LogLevel {
+	case "debug", "info", "warn":
+	default:
+		errs = append(errs, fmt.Errorf("log_level: must be debug, info or warn, not %q", cfg.LogLevel))
+	}
+
+	names := make(map[string]bool)
+	for _, mc := range cfg.mountConfigs() {
+		what := "mount"
+		if mc.Name != "" {
+			what = fmt.Sprintf("mount %q", mc.Name)
+		} else if len(cfg.Mounts) > 0 {
+			errs = append(errs, errors.New("mount: every [[mount]] needs a name"))
+		}
+		if names[mc.Name] {
+			errs = append(errs, fmt.Errorf("%s: defined twice", what))
+		}
+		names[mc.Name] = true
+		if mc.DefaultLang == nil {
+			errs = append(errs, fmt.Errorf("%s: default_lang ($DEFAULT_LANG) is not set", what))
+		}
+		if mc.DarkmodeURL == "" {
+			errs = append(errs, fmt.Errorf("%s: darkmode_url ($DARKMODE_URL) is not set", what))
+		}
+		if mc.ContentURL == "" && mc.ContentDir == "" {
+			errs = append(errs, fmt.Errorf("%s: neither content_url ($CONTENT_URL) nor content_dir ($CONTENT_DIR) is set", what))
+		}
+		if a := mc.FuzzyfileAnchors; a != nil && (*a < 0 || *a > 6) {
+			errs = append(errs, fmt.Errorf("%s: fuzzyfile_anchors ($FUZZYFILE_ANCHORS) must be between 0 and 6, not %d", what, *a))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// print writes the effective configuration to w with secrets redacted.
+func (cfg Config) print(w io.Writer) error {
+	redacted := func(mc MountConfig) MountConfig {
+		if mc.Token != "" {
+			mc.Token = "REDACTED"
+		}
+		mc.ContentURL = redact.String(mc.ContentURL)
+		return mc
+	}
+	cfg.MountConfig = redacted(cfg.MountConfig)
+	mounts := make([]MountConfig, len(cfg.Mounts))
+	for i, mc := range cfg.Mounts {
+		mounts[i] = redacted(mc)
+	}
+	cfg.Mounts = mounts
+	return toml.NewEncoder(w).Encode(cfg)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testConfig = `
+port = 8080
+default_lang = "sv"
+darkmode_url = "false"
+content_dir = "/srv/content"
+cache_control = "public, max-age=60"
+fuzzyfile_anchors = 2
+
+[[mount]]
+name = "styrdokument"
+prefix = "/styrdokument"
+cors_max_age = "1m"
+
+[[mount]]
+name = "bawang"
+fuzzyfile_anchors = 0
+`
+
+var loadconfigtests = []struct {
+	env     map[string]string
+	mount   int // Index of the mount to check.
+	setting func(MountConfig) string
+	want    string
+}{
+	// Defaults.
+	{nil, 1, func(mc MountConfig) string { return mc.Jumpfile }, "jumpfile.json"},
+	{nil, 1, func(mc MountConfig) string { return mc.CORSMaxAge.String() }, "10m0s"},
+	{nil, 0, func(mc MountConfig) string { return mc.FuzzyfileBaseURL }, "https://datasektionen.se/styrdokument"},
+	{nil, 1, func(mc MountConfig) string { return mc.FuzzyfileBaseURL }, "https://datasektionen.se"},
+	{map[string]string{"FUZZYFILE_BASE_URL": "https://bawang.datasektionen.se"}, 1, func(mc MountConfig) string { return mc.FuzzyfileBaseURL }, "https://bawang.datasektionen.se"},
+	{nil, 0, func(mc MountConfig) string { return mc.AssetBaseURL }, "/styrdokument"},
+	{nil, 1, func(mc MountConfig) string { return mc.AssetBaseURL }, "/"},
+	{nil, 1, func(mc MountConfig) string { return mc.CORSOrigins[0] }, "*"},
+	// The file over the defaults, and the mount over the top level.
+	{nil, 1, func(mc MountConfig) string { return mc.CacheControl }, "public, max-age=60"},
+	{nil, 0, func(mc MountConfig) string { return mc.CORSMaxAge.String() }, "1m0s"},
+	{nil, 1, func(mc MountConfig) string { return mc.ContentDir }, "/srv/content"},
+	{nil, 0, func(mc MountConfig) string { return strconv.Itoa(*mc.FuzzyfileAnchors) }, "2"},
+	{nil, 1, func(mc MountConfig) string { return strconv.Itoa(*mc.FuzzyfileAnchors) }, "0"},
+	// The environment over the file.
+	{map[string]string{"CACHE_CONTROL": "no-store"}, 0, func(mc MountConfig) string { return mc.CacheControl }, "no-store"},
+	{map[string]string{"CORS_MAX_AGE": "5m"}, 1, func(mc MountConfig) string { return mc.CORSMaxAge.String() }, "5m0s"},
+	{map[string]string{"CORS_MAX_AGE": "5m"}, 0, func(mc MountConfig) string { return mc.CORSMaxAge.String() }, "1m0s"},
+	{map[string]string{"STYRDOKUMENT_CORS_MAX_AGE": "5m"}, 0, func(mc MountConfig) string { return mc.CORSMaxAge.String() }, "5m0s"},
+	{map[string]string{"STYRDOKUMENT_CONTENT_DIR": "/srv/styrdokument", "CONTENT_DIR": "/srv/other"}, 0, func(mc MountConfig) string { return mc.ContentDir }, "/srv/styrdokument"},
+	{map[string]string{"CONTENT_DIR": "/srv/other"}, 1, func(mc MountConfig) string { return mc.ContentDir }, "/srv/other"},
+	{map[string]string{"ASSET_BASE_URL": "https://taitan.datasektionen.se"}, 0, func(mc MountConfig) string { return mc.AssetBaseURL }, "https://taitan.datasektionen.se"},
+	{map[string]string{"BAWANG_JUMPFILE": "redirects.json"}, 1, func(mc MountConfig) string { return mc.Jumpfile }, "redirects.json"},
+	{map[string]string{"TRUSTED_DIRS": "/sektionen/historia,/om"}, 0, func(mc MountConfig) string { return strings.Join(mc.TrustedDirs, " ") }, "/sektionen/historia /om"},
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "taitan.toml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range loadconfigtests {
+		for k, v := range tt.env {
+			os.Setenv(k, v)
+		}
+		cfg, err := loadConfig(path)
+		for k := range tt.env {
+			os.Unsetenv(k)
+		}
+		if err != nil {
+			t.Errorf("loadConfig with %v => error %v", tt.env, err)
+			continue
+		}
+		if got := tt.setting(cfg.Mounts[tt.mount]); got != tt.want {
+			t.Errorf("loadConfig with %v => %q for mount %q, want %q", tt.env, got, cfg.Mounts[tt.mount].Name, tt.want)
+		}
+	}
+}
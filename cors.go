@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsPolicy decides which cross-origin requests browsers may make.
+type corsPolicy struct {
+	origins []string // Allowed origins, "*" for any.
+	methods []string // Allowed methods.
+	headers []string // Allowed request headers, in canonical form.
+	maxAge  time.Duration
+}
+
+// allowOrigin returns the value of the Access-Control-Allow-Origin header for
+// a request from origin, or "" if origin is not allowed.
+func (p *corsPolicy) allowOrigin(origin string) string {
+	for _, o := range p.origins {
+		if o == "*" {
+			return "*"
+		}
+		if o == origin {
+			return origin
+		}
+	}
+	return ""
+}
+
+// allowMethod reports whether method is allowed.
+func (p *corsPolicy) allowMethod(method string) bool {
+	for _, m := range p.methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// allowHeaders reports whether all of the comma-separated headers are
+// allowed.
+func (p *corsPolicy) allowHeaders(headers string) bool {
+	for _, h := range splitList(headers) {
+		allowed := false
+		for _, a := range p.headers {
+			if a == http.CanonicalHeaderKey(h) {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// cors applies the CORS policy of the mount serving a request and answers
+// OPTIONS requests, including preflight requests, itself.
+func cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		m, _ := findMount(req.Host, req.URL.Path)
+		if m == nil {
+			next.ServeHTTP(res, req)
+			return
+		}
+		p := &m.cors
+
+		origin := req.Header.Get("Origin")
+		allowed := p.allowOrigin(origin)
+		if len(p.origins) != 1 || p.origins[0] != "*" {
+			res.Header().Add("Vary", "Origin")
+		}
+
+		if req.Method != http.MethodOptions {
+			if allowed != "" {
+				res.Header().Set("Access-Control-Allow-Origin", allowed)
+			}
+			next.ServeHTTP(res, req)
+			return
+		}
+
+		setRoute(res, routePreflight)
+		method := req.Header.Get("Access-Control-Request-Method")
+		if origin == "" || method == "" {
+			// Not a preflight request, just tell what we support.
+			res.Header().Set("Allow", strings.Join(append([]string{http.MethodOptions}, p.methods...), ", "))
+			res.WriteHeader(http.StatusNoContent)
+			return
+		}
+		res.Header().Add("Vary", "Access-Control-Request-Method")
+		res.Header().Add("Vary", "Access-Control-Request-Headers")
+		headers := req.Header.Get("Access-Control-Request-Headers")
+		if allowed == "" || !p.allowMethod(method) || !p.allowHeaders(headers) {
+			writeError(res, http.StatusForbidden, apiError{
+				Code:    codeForbidden,
+				Message: "Cross-origin request is not allowed",
+				Path:    req.URL.Path,
+			})
+			return
+		}
+		res.Header().Set("Access-Control-Allow-Origin", allowed)
+		res.Header().Set("Access-Control-Allow-Methods", strings.Join(p.methods, ", "))
+		if len(p.headers) > 0 {
+			res.Header().Set("Access-Control-Allow-Headers", strings.Join(p.headers, ", "))
+		}
+		if p.maxAge > 0 {
+			res.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(p.maxAge.Seconds())))
+		}
+		res.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var corstests = []struct {
+	method  string
+	headers map[string]string
+	code    int
+	allow   map[string]string // Response headers, "" for absent.
+}{
+	// Preflight requests.
+	{http.MethodOptions, map[string]string{"Origin": "https://datasektionen.se", "Access-Control-Request-Method": "GET"}, http.StatusNoContent, map[string]string{
+		"Access-Control-Allow-Origin":  "https://datasektionen.se",
+		"Access-Control-Allow-Methods": "GET, HEAD",
+		"Access-Control-Allow-Headers": "X-Requested-With",
+		"Access-Control-Max-Age":       "600",
+	}},
+	{http.MethodOptions, map[string]string{"Origin": "https://datasektionen.se", "Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "x-requested-with"}, http.StatusNoContent, map[string]string{
+		"Access-Control-Allow-Origin": "https://datasektionen.se",
+	}},
+	{http.MethodOptions, map[string]string{"Origin": "https://datasektionen.se", "Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "X-Github-Event"}, http.StatusForbidden, map[string]string{
+		"Access-Control-Allow-Origin": "",
+	}},
+	{http.MethodOptions, map[string]string{"Origin": "https://datasektionen.se", "Access-Control-Request-Method": "DELETE"}, http.StatusForbidden, map[string]string{
+		"Access-Control-Allow-Origin": "",
+	}},
+	{http.MethodOptions, map[string]string{"Origin": "https://evil.example", "Access-Control-Request-Method": "GET"}, http.StatusForbidden, map[string]string{
+		"Access-Control-Allow-Origin": "",
+	}},
+	// OPTIONS without preflight.
+	{http.MethodOptions, nil, http.StatusNoContent, map[string]string{
+		"Allow":                       "OPTIONS, GET, HEAD",
+		"Access-Control-Allow-Origin": "",
+	}},
+	// Simple requests.
+	{http.MethodGet, map[string]string{"Origin": "https://datasektionen.se"}, http.StatusOK, map[string]string{
+		"Access-Control-Allow-Origin": "https://datasektionen.se",
+		"Vary":                        "Origin",
+	}},
+	{http.MethodGet, map[string]string{"Origin": "https://evil.example"}, http.StatusOK, map[string]string{
+		"Access-Control-Allow-Origin": "",
+	}},
+}
+
+func TestCORS(t *testing.T) {
+	defer func(ms []*mount) { mounts = ms }(mounts)
+	mounts = []*mount{{
+		prefix: "/",
+		cors: corsPolicy{
+			origins: []string{"https://datasektionen.se"},
+			methods: []string{http.MethodGet, http.MethodHead},
+			headers: []string{"X-Requested-With"},
+			maxAge:  10 * time.Minute,
+		},
+	}}
+	handler := cors(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {}))
+	for _, tt := range corstests {
+		req := httptest.NewRequest(tt.method, "/sektionen", nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		if res.Code != tt.code {
+			t.Errorf("%s with %v => %d, want %d", tt.method, tt.headers, res.Code, tt.code)
+		}
+		for k, want := range tt.allow {
+			if got := res.Header().Get(k); got != want {
+				t.Errorf("%s with %v => %s: %q, want %q", tt.method, tt.headers, k, got, want)
+			}
+		}
+	}
+}
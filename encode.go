@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Content codings we can serve, in order of preference.
+var codings = []string{"br", "gzip"}
+
+// encoded is a serialized response together with its compressed variants,
+// which are created the first time they are requested.
+type encoded struct {
+	mu       sync.Mutex
+	variants map[string][]byte // By content coding, "identity" for none.
+}
+
+// encodedCache holds the serialized responses of a snapshot.
+type encodedCache struct {
+	mu      sync.Mutex
+	entries map[string]*encoded
+}
+
+func newEncodedCache() *encodedCache {
+	return &encodedCache{entries: make(map[string]*encoded)}
+}
+
+// get returns the response cached under key, serializing it with build if it
+// isn't cached yet.
+func (c *encodedCache) get(key string, build func() ([]byte, error)) (*encoded, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		return e, nil
+	}
+	e, err := newEncoded(build)
+	if err != nil {
+		return nil, err
+	}
+	c.entries[key] = e
+	return e, nil
+}
+
+// newEncoded serializes a response with build, without caching it.
+func newEncoded(build func() ([]byte, error)) (*encoded, error) {
+	buf, err := build()
+	if err != nil {
+		return nil, err
+	}
+	return &encoded{variants: map[string][]byte{"identity": buf}}, nil
+}
+
+// variant returns the body of e in coding.
+func (e *encoded) variant(coding string) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if buf, ok := e.variants[coding]; ok {
+		return buf, nil
+	}
+	var b bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "br":
+		w = brotli.NewWriterLevel(&b, 6)
+	case "gzip":
+		w, _ = gzip.NewWriterLevel(&b, gzip.BestCompression)
+	}
+	if _, err := w.Write(e.variants["identity"]); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	e.variants[coding] = b.Bytes()
+	return e.variants[coding], nil
+}
+
+// acceptedCoding returns the content coding that a response to req is
+// written in by encoded.write, and marks res as varying with it.
+func acceptedCoding(res http.ResponseWriter, req *http.Request) string {
+	res.Header().Add("Vary", "Accept-Encoding")
+	return negotiate(req.Header.Get("Accept-Encoding"))
+}
+
+// write writes e to res in coding, falling back to identity if it can't be
+// compressed. Each coding gets its own entity tag derived from tag.
+func (e *encoded) write(res http.ResponseWriter, coding, tag string) {
+	buf, err := e.variant(coding)
+	if err != nil {
+		coding = "identity"
+		buf, _ = e.variant(coding)
+	}
+	if coding != "identity" {
+		res.Header().Set("Content-Encoding", coding)
+	}
+	res.Header().Set("ETag", codingTag(tag, coding))
+	res.Header().Set("Content-Length", strconv.Itoa(len(buf)))
+	res.Write(buf)
+}
+
+// negotiate returns the preferred coding of ours accepted by the
+// Accept-Encoding header value ae, or "identity".
+func negotiate(ae string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(ae, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				q, _ = strconv.ParseFloat(v, 64)
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(coding))] = q > 0
+	}
+	for _, coding := range codings {
+		if ok, found := accepted[coding]; ok || !found && accepted["*"] {
+			return coding
+		}
+	}
+	return "identity"
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+var negotiatetests = []struct {
+	in, out string
+}{
+	{"", "identity"},
+	{"gzip", "gzip"},
+	{"gzip, br", "br"},
+	{"br;q=0.5, gzip", "br"},
+	{"br;q=0, gzip", "gzip"},
+	{"BR", "br"},
+	{"gzip;q=0", "identity"},
+	{"*", "br"},
+	{"*, br;q=0", "gzip"},
+	{"*;q=0", "identity"},
+	{"deflate", "identity"},
+	{"gzip; q=0.001", "gzip"},
+}
+
+func TestNegotiate(t *testing.T) {
+	for _, tt := range negotiatetests {
+		got := negotiate(tt.in)
+		if got != tt.out {
+			t.Errorf("negotiate(%q) => %q, want %q", tt.in, got, tt.out)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	e, err := newEncoded(func() ([]byte, error) { return []byte(`{"title":"Hej"}`), nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, coding := range []string{"identity", "gzip", "br"} {
+		res := httptest.NewRecorder()
+		e.write(res, coding, `"abc"`)
+		wantEncoding := coding
+		if coding == "identity" {
+			wantEncoding = ""
+		}
+		if got := res.Header().Get("Content-Encoding"); got != wantEncoding {
+			t.Errorf("write(%q) => Content-Encoding %q, want %q", coding, got, wantEncoding)
+		}
+		if got, want := res.Header().Get("ETag"), codingTag(`"abc"`, coding); got != want {
+			t.Errorf("write(%q) => ETag %q, want %q", coding, got, want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+
+	"github.com/datasektionen/taitan/pages"
+	"github.com/datasektionen/taitan/suggest"
+	log "github.com/sirupsen/logrus"
+)
+
+// Error codes of error responses.
+const (
+	codeNotFound     = "page_not_found"
+	codeLangNotFound = "language_not_found"
+	codeNotLoaded    = "content_not_loaded"
+	codeForbidden    = "cors_forbidden"
+	codeInternal     = "internal_error"
+)
+
+// apiError is the body of error responses.
+type apiError struct {
+	Code        string   `json:"code"`    // Machine-readable code, one of the code* constants.
+	Message     string   `json:"message"` // Human-readable description.
+	Path        string   `json:"path"`
+	Lang        string   `json:"lang,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"` // Existing pages close to the requested path.
+	Languages   []string `json:"languages,omitempty"`   // Languages the requested page exists in.
+}
+
+// writeError writes e as the response with status.
+func writeError(res http.ResponseWriter, status int, e apiError) {
+	buf, err := json.Marshal(e)
+	if err != nil {
+		log.Warnf("writeError: unexpected error: %#v\n", err)
+		res.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	res.Header().Del("ETag")
+	res.Header().Del("Last-Modified")
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	res.Header().Set("Cache-Control", "no-store")
+	res.WriteHeader(status)
+	res.Write(buf)
+}
+
+// closestAncestor returns the closest existing page above p, or "" if there
+// is none other than the root.
+func closestAncestor(resps map[string]*pages.Page, p string) string {
+	for p != "/" {
+		p = path.Dir(p)
+		if _, ok := resps[p]; ok && p != "/" {
+			return p
+		}
+	}
+	return ""
+}
+
+// maxSuggestions is the largest number of suggestions in a response.
+const maxSuggestions = 5
+
+// suggestPaths returns the pages close to p, which doesn't exist.
+func suggestPaths(resps map[string]*pages.Page, p string) []suggest.Candidate {
+	paths := make([]string, 0, len(resps))
+	for q := range resps {
+		paths = append(paths, q)
+	}
+	return suggest.Suggest(paths, p, maxSuggestions)
+}
+
+// languages returns the languages page exists in, sorted.
+func languages(page *pages.Page) []string {
+	var langs []string
+	for lang := range page.Titles {
+		if responseExistForLang(page, lang) {
+			langs = append(langs, lang)
+		}
+	}
+	sort.Strings(langs)
+	return langs
+}
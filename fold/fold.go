@@ -0,0 +1,23 @@
+// Package fold folds text so that it matches regardless of case and
+// diacritics, as in paths, ids and search terms.
+package fold
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// String returns s in lower case without diacritics, so that e.g. "Nämnder"
+// and "namnder" fold to the same string.
+func String(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, s)
+	if err != nil {
+		folded = s
+	}
+	return strings.ToLower(folded)
+}
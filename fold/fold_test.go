@@ -0,0 +1,23 @@
+package fold
+
+import (
+	"testing"
+)
+
+var stringtests = []struct {
+	in, out string
+}{
+	{"/sektionen/nämnder", "/sektionen/namnder"},
+	{"/Sektionen/Nämnder", "/sektionen/namnder"},
+	{"/om-oss/åre-öl", "/om-oss/are-ol"},
+	{"/plain", "/plain"},
+}
+
+func TestString(t *testing.T) {
+	for _, tt := range stringtests {
+		got := String(tt.in)
+		if got != tt.out {
+			t.Errorf("String(%q) => %q, want %q", tt.in, got, tt.out)
+		}
+	}
+}
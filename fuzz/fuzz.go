@@ -1,7 +1,8 @@
 package fuzz
 
 import (
-	"fmt"
+	"net/url"
+	"sort"
 
 	"github.com/datasektionen/taitan/pages"
 )
@@ -16,30 +17,84 @@ type File struct {
 type Fuzz struct {
 	Name  string `json:"name"`            // title
 	Str   string `json:"str"`             // slug
-	Color string `json:"color,omitempty"` // wtf
-	Href  string `json:"href"`            // fmt.Sprintf("http://datasektionen.se%s", slug)
+	Color string `json:"color,omitempty"` // color from meta.toml
+	Href  string `json:"href"`            // baseURL + path
 }
 
-// NewFile returns a fuzzyfile.
-func NewFile(resp map[string]*pages.Page) File {
-	fs := make([]Fuzz, 0, 128)
-	for path, r := range resp {
-		title, ok := r.Titles[""]
-		if !ok {
-			for _, title_ := range r.Titles {
-				title = title_
-				break
-			}
-		}
+// Options are the options of a fuzzyfile.
+type Options struct {
+	BaseURL     string // Base URL of the hrefs.
+	Lang        string // Language of the fuzzyfile, "" for none.
+	DefaultLang string // Language of the titles if Lang is "".
+	AnchorLevel int    // Include headings up to this level, 0 for none.
+}
+
+// NewFile returns a fuzzyfile with hrefs to the pages under opts.BaseURL,
+// sorted by path, each followed by its headings up to opts.AnchorLevel. If
+// opts.Lang is set, only pages in that language are included, with their
+// titles in it and hrefs to them in it. Otherwise all pages are included,
+// with their titles in opts.DefaultLang if they have one.
+func NewFile(resp map[string]*pages.Page, opts Options) File {
+	paths := make([]string, 0, len(resp))
+	for path := range resp {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
 
+	lang := opts.Lang
+	query := ""
+	if lang != "" {
+		query = "?" + url.Values{"lang": {lang}}.Encode()
+	} else {
+		lang = opts.DefaultLang
+	}
+	fs := make([]Fuzz, 0, len(paths))
+	for _, path := range paths {
+		r := resp[path]
+		if _, ok := r.Bodies[lang]; opts.Lang != "" && !ok {
+			continue
+		}
+		name := title(r, lang, opts.DefaultLang)
+		href := opts.BaseURL + path + query
 		fs = append(fs, Fuzz{
-			Name: title,
-			Str:  r.Slug,
-			Href: fmt.Sprintf("http://datasektionen.se%s", path),
+			Name:  name,
+			Str:   r.Slug,
+			Color: r.Color,
+			Href:  href,
 		})
+		for _, a := range r.Anchors[lang] {
+			if a.HeaderLevel > opts.AnchorLevel {
+				continue
+			}
+			fs = append(fs, Fuzz{
+				Name:  name + " › " + a.Value,
+				Str:   r.Slug + "#" + a.ID,
+				Color: r.Color,
+				Href:  href + "#" + a.ID,
+			})
+		}
 	}
 	return File{
 		Type:   "fuzzyfile",
 		Fuzzes: fs,
 	}
 }
+
+// title returns the title of r in the first of langs it has one in, or in
+// the first of its languages in sorted order.
+func title(r *pages.Page, langs ...string) string {
+	for _, lang := range append(langs, "") {
+		if t, ok := r.Titles[lang]; ok {
+			return t
+		}
+	}
+	keys := make([]string, 0, len(r.Titles))
+	for lang := range r.Titles {
+		keys = append(keys, lang)
+	}
+	sort.Strings(keys)
+	if len(keys) == 0 {
+		return ""
+	}
+	return r.Titles[keys[0]]
+}
@@ -0,0 +1,74 @@
+package fuzz
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/datasektionen/taitan/pages"
+)
+
+var testPages = map[string]*pages.Page{
+	"/sektionen": {
+		Titles: pages.LangLookup{"sv": "Sektionen", "en": "The chapter"},
+		Bodies: pages.LangLookup{"sv": "", "en": ""},
+		Slug:   "sektionen",
+		Color:  "#e83d84",
+		Anchors: pages.LangAnchorLookup{
+			"sv": {{ID: "styrelsen", Value: "Styrelsen", HeaderLevel: 1}, {ID: "kassor", Value: "Kassör", HeaderLevel: 2}},
+			"en": {{ID: "the-board", Value: "The board", HeaderLevel: 1}},
+		},
+	},
+	"/nyheter": {
+		Titles: pages.LangLookup{"sv": "Nyheter"},
+		Bodies: pages.LangLookup{"sv": ""},
+		Slug:   "nyheter",
+	},
+}
+
+var newfiletests = []struct {
+	lang  string
+	level int
+	out   []Fuzz
+}{
+	{"", 0, []Fuzz{
+		{"Nyheter", "nyheter", "", "https://datasektionen.se/nyheter"},
+		{"Sektionen", "sektionen", "#e83d84", "https://datasektionen.se/sektionen"},
+	}},
+	{"sv", 0, []Fuzz{
+		{"Nyheter", "nyheter", "", "https://datasektionen.se/nyheter?lang=sv"},
+		{"Sektionen", "sektionen", "#e83d84", "https://datasektionen.se/sektionen?lang=sv"},
+	}},
+	{"en", 0, []Fuzz{
+		{"The chapter", "sektionen", "#e83d84", "https://datasektionen.se/sektionen?lang=en"},
+	}},
+	{"de", 0, []Fuzz{}},
+	{"", 1, []Fuzz{
+		{"Nyheter", "nyheter", "", "https://datasektionen.se/nyheter"},
+		{"Sektionen", "sektionen", "#e83d84", "https://datasektionen.se/sektionen"},
+		{"Sektionen › Styrelsen", "sektionen#styrelsen", "#e83d84", "https://datasektionen.se/sektionen#styrelsen"},
+	}},
+	{"sv", 6, []Fuzz{
+		{"Nyheter", "nyheter", "", "https://datasektionen.se/nyheter?lang=sv"},
+		{"Sektionen", "sektionen", "#e83d84", "https://datasektionen.se/sektionen?lang=sv"},
+		{"Sektionen › Styrelsen", "sektionen#styrelsen", "#e83d84", "https://datasektionen.se/sektionen?lang=sv#styrelsen"},
+		{"Sektionen › Kassör", "sektionen#kassor", "#e83d84", "https://datasektionen.se/sektionen?lang=sv#kassor"},
+	}},
+	{"en", 6, []Fuzz{
+		{"The chapter", "sektionen", "#e83d84", "https://datasektionen.se/sektionen?lang=en"},
+		{"The chapter › The board", "sektionen#the-board", "#e83d84", "https://datasektionen.se/sektionen?lang=en#the-board"},
+	}},
+}
+
+func TestNewFile(t *testing.T) {
+	for _, tt := range newfiletests {
+		got := NewFile(testPages, Options{
+			BaseURL:     "https://datasektionen.se",
+			Lang:        tt.lang,
+			DefaultLang: "sv",
+			AnchorLevel: tt.level,
+		}).Fuzzes
+		if !reflect.DeepEqual(got, tt.out) {
+			t.Errorf("NewFile(%q, %d) => %v, want %v", tt.lang, tt.level, got, tt.out)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/datasektionen/taitan/redact"
+	log "github.com/sirupsen/logrus"
+)
+
+// tokenEnv is the environment variable the credential helper reads the token
+// from. It is only set in the environment of the git processes we start.
+const tokenEnv = "TAITAN_GIT_TOKEN"
+
+// credentialHelper hands the token to git when it asks for credentials, so
+// that it never has to be part of the remote URL or the command line.
+const credentialHelper = `!f() { test "$1" = get && echo username=x-access-token && echo "password=$` + tokenEnv + `"; }; f`
+
+// gitAuth holds the credentials used when talking to the content remote.
+type gitAuth struct {
+	token      string // HTTPS token, passed through a credential helper.
+	tokenURL   string // Scheme and host the token is given to, e.g. https://github.com.
+	sshKey     string // Path to an SSH deploy key.
+	knownHosts string // Path to a known_hosts file used together with sshKey.
+}
+
+// remote returns the content remote of m without any credentials together
+// with the credentials to use for it. A token given as the userinfo of an
+// HTTP(S) content URL is still accepted but moved to the credential helper.
+// Other remotes, such as the scp-like git@github.com:datasektionen/bawang-content.git
+// of SSH, are returned as they are.
+func (m *mount) remote() (string, gitAuth, error) {
+	auth := m.auth
+	lower := strings.ToLower(m.contentURL)
+	if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+		return m.contentURL, auth, nil
+	}
+	u, err := url.Parse(m.contentURL)
+	if err != nil {
+		return "", gitAuth{}, fmt.Errorf("Could not parse content URL: %s", redact.String(err.Error()))
+	}
+	if u.User != nil {
+		if auth.token == "" {
+			if password, ok := u.User.Password(); ok {
+				auth.token = password
+			} else {
+				auth.token = u.User.Username()
+			}
+		}
+		u.User = nil
+	}
+	if auth.token != "" {
+		auth.tokenURL = u.Scheme + "://" + u.Host
+	}
+	redact.Add(auth.token)
+	return u.String(), auth, nil
+}
+
+// root returns the directory the content of m is served from. Named mounts
+// are cloned into a directory of their own name, as several of them may use
+// the same repository, and the single unnamed mount into one named after the
+// repository.
+func (m *mount) root() string {
+	if m.contentDir != "" {
+		return m.contentDir
+	}
+	if m.name != "" {
+		return m.name
+	}
+	remote, _, err := m.remote()
+	if err != nil {
+		log.Fatalln("root: ", err)
+	}
+	if u, err := url.Parse(remote); err == nil && u.Scheme != "" && u.Host != "" {
+		remote = u.Path
+	}
+	base := path.Base(remote)
+	// The path of an scp-like remote without directories follows the host.
+	if i := strings.LastIndex(base, ":"); i >= 0 {
+		base = base[i+1:]
+	}
+	return strings.TrimSuffix(base, path.Ext(base))
+}
+
+// fetch clones or pulls the content repository of m. It does nothing if the
+// content is served from a local directory.
+func (m *mount) fetch() error {
+	if m.contentDir != "" {
+		return nil
+	}
+	remote, auth, err := m.remote()
+	if err != nil {
+		return err
+	}
+
+	root := m.root()
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		args := []string{"clone"}
+		if m.ref != "" {
+			args = append(args, "--branch", m.ref)
+		}
+		if err := runGit(auth, "clone", append(args, remote, root)...); err != nil {
+			return err
+		}
+		if err := runGit(auth, "submodule init", "-C", root, "submodule", "init"); err != nil {
+			return err
+		}
+		if err := runGit(auth, "submodule update", "-C", root, "submodule", "update"); err != nil {
+			return err
+		}
+	} else {
+		// Checkouts made by older versions have the token stored in the
+		// remote URL in .git/config.
+		if err := runGit(auth, "remote set-url", "-C", root, "remote", "set-url", "origin", remote); err != nil {
+			return err
+		}
+		args := []string{"-C", root, "pull"}
+		if m.ref != "" {
+			args = append(args, "origin", m.ref)
+		}
+		if err := runGit(auth, "pull", args...); err != nil {
+			return err
+		}
+		if err := runGit(auth, "submodule update", "-C", root, "submodule", "update"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revision returns the commit checked out in root, or "" if root is not a
+// git repository.
+func revision(root string) (string, error) {
+	if _, err := os.Stat(filepath.Join(root, ".git")); err != nil {
+		return "", nil
+	}
+	out, err := exec.Command("git", "-C", root, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// env returns the environment for a git process using these credentials.
+func (a gitAuth) env() []string {
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if a.token != "" {
+		env = append(env, tokenEnv+"="+a.token)
+	}
+	if a.sshKey != "" {
+		ssh := fmt.Sprintf("ssh -i %q -o IdentitiesOnly=yes", a.sshKey)
+		if a.knownHosts != "" {
+			ssh += fmt.Sprintf(" -o UserKnownHostsFile=%q -o StrictHostKeyChecking=yes", a.knownHosts)
+		} else {
+			ssh += " -o StrictHostKeyChecking=accept-new"
+		}
+		env = append(env, "GIT_SSH_COMMAND="+ssh)
+	}
+	return env
+}
+
+// args prepends the configuration needed to use these credentials to args.
+// The credential helper is only configured for the host of the remote, so
+// that submodules or redirects to other hosts never get the token.
+func (a gitAuth) args(args []string) []string {
+	if a.token == "" || a.tokenURL == "" {
+		return args
+	}
+	// The empty helper resets any helpers configured on the system.
+	return append([]string{"-c", "credential.helper=", "-c", "credential." + a.tokenURL + ".helper=" + credentialHelper}, args...)
+}
+
+func runGit(auth gitAuth, action string, args ...string) error {
+	log.Infof("Found root directory - %sing updates!", action)
+	args = auth.args(args)
+	log.Debugf("Commands %#v!", args)
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Env = auth.env()
+	cmd.Stderr = &stderr
+	err := cmd.Start()
+	if err != nil {
+		return fmt.Errorf("Could not start %sing: %w\n", action, err)
+	}
+	log.Infof("Waiting for git %s to finish...", action)
+	start := time.Now()
+	err = cmd.Wait()
+	gitDuration.WithLabelValues(action, result(err)).Observe(time.Since(start).Seconds())
+	if err != nil {
+		return fmt.Errorf("Could not %s: %w: %s\n", action, err, redact.String(strings.TrimSpace(stderr.String())))
+	}
+
+	log.Infof("Git %s finished!", action)
+	return nil
+}
@@ -4,13 +4,25 @@ go 1.22.1
 
 require (
 	github.com/BurntSushi/toml v0.3.1
+	github.com/alecthomas/chroma/v2 v2.14.0
+	github.com/andybalholm/brotli v1.1.0
+	github.com/prometheus/client_golang v1.19.1
 	github.com/rjeczalik/notify v0.9.3
 	github.com/russross/blackfriday v1.6.0
 	github.com/sirupsen/logrus v1.9.0
+	github.com/yuin/goldmark v1.7.4
 	golang.org/x/net v0.22.0
+	golang.org/x/text v0.14.0
 )
 
 require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dlclark/regexp2 v1.11.0 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
 	github.com/stretchr/testify v1.9.0 // indirect
 	golang.org/x/sys v0.18.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
 )
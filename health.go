@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	started        = time.Now() // When the process started.
+	readyTolerance time.Duration
+)
+
+// mountStatus is the readiness of a mount.
+type mountStatus struct {
+	Name      string     `json:"name"`
+	Ready     bool       `json:"ready"`
+	Commit    string     `json:"commit,omitempty"`    // The served commit.
+	Pages     int        `json:"pages"`               // Number of loaded pages.
+	LoadedAt  *time.Time `json:"loaded_at,omitempty"` // When the content was last loaded.
+	Darkmode  *bool      `json:"darkmode"`            // null if unknown.
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// healthResp is the response of /_health and /_ready.
+type healthResp struct {
+	Status string        `json:"status"` // "ok" or "unavailable".
+	Uptime float64       `json:"uptime"` // Seconds since start.
+	Mounts []mountStatus `json:"mounts,omitempty"`
+}
+
+// readiness returns the readiness of m. It is ready when its content is loaded,
+// the last reload succeeded or has failed for less than readyTolerance, and
+// its darkmode status has been fetched.
+func (m *mount) readiness() mountStatus {
+	s := mountStatus{Name: m.String()}
+
+	m.responses.Lock()
+	loaded := m.responses.Resps != nil
+	s.Commit = m.responses.Commit
+	s.Pages = len(m.responses.Resps)
+	if loaded {
+		loadedAt := m.responses.LoadedAt
+		s.LoadedAt = &loadedAt
+	}
+	m.responses.Unlock()
+
+	m.darkmode.mu.Lock()
+	if m.darkmode.known {
+		darkmode := m.darkmode.result
+		s.Darkmode = &darkmode
+	}
+	m.darkmode.mu.Unlock()
+
+	m.status.Lock()
+	failing := m.status.lastError != nil && time.Since(m.status.failingSince) > readyTolerance
+	if m.status.lastError != nil {
+		s.LastError = m.status.lastError.Error()
+	}
+	m.status.Unlock()
+
+	s.Ready = loaded && !failing && s.Darkmode != nil
+	return s
+}
+
+// healthHandler tells whether the process is alive.
+func healthHandler(res http.ResponseWriter, req *http.Request) {
+	writeHealth(res, http.StatusOK, healthResp{Status: "ok", Uptime: time.Since(started).Seconds()})
+}
+
+// readyHandler tells whether all mounts are ready to serve requests.
+func readyHandler(res http.ResponseWriter, req *http.Request) {
+	resp := healthResp{Status: "ok", Uptime: time.Since(started).Seconds()}
+	code := http.StatusOK
+	for _, m := range mounts {
+		s := m.readiness()
+		if !s.Ready {
+			resp.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+		}
+		resp.Mounts = append(resp.Mounts, s)
+	}
+	writeHealth(res, code, resp)
+}
+
+func writeHealth(res http.ResponseWriter, code int, resp healthResp) {
+	buf, err := json.Marshal(resp)
+	if err != nil {
+		log.Warnf("writeHealth: unexpected error: %#v\n", err)
+		res.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	res.Header().Set("Cache-Control", "no-store")
+	res.WriteHeader(code)
+	res.Write(buf)
+}
@@ -0,0 +1,145 @@
+// Package highlight highlights the syntax of code blocks in HTML.
+package highlight
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// DefaultStyle is the style of the CSS, unless another one is asked for.
+const DefaultStyle = "github"
+
+// class is the class of highlighted <pre> elements, which the CSS is scoped to.
+const class = "chroma"
+
+// formatter writes the tokens of a code block as spans with classes, so
+// that the style is left to the CSS.
+var formatter = chromahtml.New(
+	chromahtml.WithClasses(true),
+	chromahtml.PreventSurroundingPre(true),
+)
+
+// Code highlights the code blocks in the HTML body, which markdown renders
+// as <pre><code class="language-go">, and returns the resulting HTML. The
+// tokens become spans with classes styled by CSS, and the <pre> elements
+// get the class "chroma". Code blocks without a language, or in a language
+// that isn't known, are left as they are.
+func Code(body string) (string, error) {
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(body), context)
+	if err != nil {
+		return "", err
+	}
+
+	var blocks []*html.Node
+	var find func(*html.Node)
+	find = func(n *html.Node) {
+		if n.DataAtom == atom.Code && n.Parent != nil && n.Parent.DataAtom == atom.Pre {
+			blocks = append(blocks, n)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	for _, n := range nodes {
+		find(n)
+	}
+	if len(blocks) == 0 {
+		return body, nil
+	}
+
+	for _, code := range blocks {
+		lexer := lexers.Get(language(code))
+		if lexer == nil {
+			continue
+		}
+		tokens, err := chroma.Coalesce(lexer).Tokenise(nil, text(code))
+		if err != nil {
+			return "", err
+		}
+		var buf bytes.Buffer
+		if err := formatter.Format(&buf, styles.Fallback, tokens); err != nil {
+			return "", err
+		}
+		spans, err := html.ParseFragment(&buf, code)
+		if err != nil {
+			return "", err
+		}
+		for c := code.FirstChild; c != nil; c = code.FirstChild {
+			code.RemoveChild(c)
+		}
+		for _, s := range spans {
+			code.AppendChild(s)
+		}
+		addClass(code.Parent, class)
+	}
+
+	var b strings.Builder
+	for _, n := range nodes {
+		if err := html.Render(&b, n); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// CSS returns the stylesheet of highlighted code in the named chroma style,
+// and whether there is such a style.
+func CSS(style string) ([]byte, bool) {
+	s, ok := styles.Registry[strings.ToLower(style)]
+	if !ok {
+		return nil, false
+	}
+	var buf bytes.Buffer
+	if err := formatter.WriteCSS(&buf, s); err != nil {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// language returns the language of a code block from its class
+// "language-x", or "" if it has none.
+func language(code *html.Node) string {
+	for _, attr := range code.Attr {
+		if attr.Key != "class" {
+			continue
+		}
+		for _, c := range strings.Fields(attr.Val) {
+			if lang, ok := strings.CutPrefix(c, "language-"); ok {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+// text returns the text content of n.
+func text(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(text(c))
+	}
+	return b.String()
+}
+
+func addClass(n *html.Node, class string) {
+	for i, attr := range n.Attr {
+		if attr.Key == "class" {
+			n.Attr[i].Val = strings.TrimSpace(attr.Val + " " + class)
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: "class", Val: class})
+}
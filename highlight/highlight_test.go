@@ -0,0 +1,47 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+)
+
+var codetests = []struct {
+	in, out string
+}{
+	{
+		`<pre><code class="language-go">x := "a&lt;b"
+</code></pre>`,
+		`<pre class="chroma"><code class="language-go"><span class="nx">x</span> <span class="o">:=</span> <span class="s">&#34;a&lt;b&#34;</span>
+</code></pre>`,
+	},
+	{
+		`<pre class="wide"><code class="language-sh">echo hej</code></pre>`,
+		`<pre class="wide chroma"><code class="language-sh"><span class="nb">echo</span> hej</code></pre>`,
+	},
+	{"<pre><code>ls</code></pre>", "<pre><code>ls</code></pre>"},
+	{`<pre><code class="language-okänt">ls</code></pre>`, `<pre><code class="language-okänt">ls</code></pre>`},
+	{`<p><code class="language-go">x</code></p>`, `<p><code class="language-go">x</code></p>`},
+}
+
+func TestCode(t *testing.T) {
+	for _, tt := range codetests {
+		got, err := Code(tt.in)
+		if err != nil {
+			t.Errorf("Code(%q) => error %v", tt.in, err)
+			continue
+		}
+		if got != tt.out {
+			t.Errorf("Code(%q) => %q, want %q", tt.in, got, tt.out)
+		}
+	}
+}
+
+func TestCSS(t *testing.T) {
+	css, ok := CSS(DefaultStyle)
+	if !ok || !strings.Contains(string(css), ".chroma .k ") {
+		t.Errorf("CSS(%q) => %q, %v, want rules for .chroma", DefaultStyle, css, ok)
+	}
+	if _, ok := CSS("okänd"); ok {
+		t.Errorf("CSS(%q) => true, want false", "okänd")
+	}
+}
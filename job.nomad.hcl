@@ -1,74 +1,39 @@
 job "taitan" {
   type = "service"
 
-  group "bawang" {
+  group "taitan" {
     network {
       port "http" { }
     }
 
     service {
-      name     = "taitan-bawang"
+      name     = "taitan"
       port     = "http"
       provider = "nomad"
       tags = [
         "traefik.enable=true",
-        "traefik.http.routers.taitan-bawang.rule=Host(`taitan.datasektionen.se`)",
-        "traefik.http.routers.taitan-bawang.tls.certresolver=default",
+        "traefik.http.routers.taitan.rule=Host(`taitan.datasektionen.se`) || Host(`taitan-styrdokument.datasektionen.se`)",
+        "traefik.http.routers.taitan.tls.certresolver=default",
 
-        "traefik.http.routers.taitan-bawang-internal.rule=Host(`taitan.nomad.dsekt.internal`)",
-        "traefik.http.routers.taitan-bawang-internal.entrypoints=web-internal",
+        "traefik.http.routers.taitan-internal.rule=Host(`taitan.nomad.dsekt.internal`) || Host(`taitan-styrdokument.nomad.dsekt.internal`)",
+        "traefik.http.routers.taitan-internal.entrypoints=web-internal",
       ]
-    }
-
-    task "taitan" {
-      driver = "docker"
-
-      config {
-        image = var.image_tag
-        ports = ["http"]
-      }
-
-      template {
-        data        = <<ENV
-PORT={{ env "NOMAD_PORT_http" }}
-DARKMODE_URL=https://darkmode.datasektionen.se/
-CONTENT_URL=https://github.com/datasektionen/bawang-content.git
-TOKEN={{ with nomadVar "nomad/jobs/taitan" }}{{ .bawang_content_token }}{{ end }}
-DEFAULT_LANG=sv
-ENV
-        destination = "local/.env"
-        env         = true
-      }
 
-      resources {
-        memory = 30
+      check {
+        type     = "http"
+        path     = "/_ready"
+        interval = "10s"
+        timeout  = "2s"
       }
     }
-  }
-
-  group "styrdokument" {
-    network {
-      port "http" { }
-    }
-
-    service {
-      name     = "taitan-styrdokument"
-      port     = "http"
-      provider = "nomad"
-      tags = [
-        "traefik.enable=true",
-        "traefik.http.routers.taitan-styrdokument.rule=Host(`taitan-styrdokument.datasektionen.se`)",
-        "traefik.http.routers.taitan-styrdokument.tls.certresolver=default",
-
-        "traefik.enable=true",
-        "traefik.http.routers.taitan-styrdokument-internal.rule=Host(`taitan-styrdokument.nomad.dsekt.internal`)",
-        "traefik.http.routers.taitan-styrdokument-internal.entrypoints=web-internal",
-      ]
-    }
 
     task "taitan" {
       driver = "docker"
 
+      # Leave time to drain connections and finish a running reload, see
+      # SHUTDOWN_TIMEOUT.
+      kill_timeout = "30s"
+
       config {
         image = var.image_tag
         ports = ["http"]
@@ -78,16 +43,23 @@ ENV
         data        = <<ENV
 PORT={{ env "NOMAD_PORT_http" }}
 DARKMODE_URL=https://darkmode.datasektionen.se/
-CONTENT_URL=https://github.com/datasektionen/styrdokument.git
-TOKEN={{ with nomadVar "nomad/jobs/taitan" }}{{ .styrdokument_token }}{{ end }}
 DEFAULT_LANG=sv
+MOUNTS=bawang,styrdokument
+BAWANG_HOST=taitan.datasektionen.se,taitan.nomad.dsekt.internal
+BAWANG_CONTENT_URL=https://github.com/datasektionen/bawang-content.git
+BAWANG_TOKEN={{ with nomadVar "nomad/jobs/taitan" }}{{ .bawang_content_token }}{{ end }}
+BAWANG_FUZZYFILE_BASE_URL=https://datasektionen.se
+STYRDOKUMENT_HOST=taitan-styrdokument.datasektionen.se,taitan-styrdokument.nomad.dsekt.internal
+STYRDOKUMENT_CONTENT_URL=https://github.com/datasektionen/styrdokument.git
+STYRDOKUMENT_TOKEN={{ with nomadVar "nomad/jobs/taitan" }}{{ .styrdokument_token }}{{ end }}
+STYRDOKUMENT_FUZZYFILE_BASE_URL=https://styrdokument.datasektionen.se
 ENV
         destination = "local/.env"
         env         = true
       }
 
       resources {
-        memory = 30
+        memory = 100
       }
     }
   }
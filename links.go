@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/datasektionen/taitan/pages"
+	log "github.com/sirupsen/logrus"
+)
+
+// brokenLinksPath is the path of the broken links of a mount, inside it.
+const brokenLinksPath = "/_broken-links"
+
+// brokenLinksResp is the response of brokenLinksPath.
+type brokenLinksResp struct {
+	Count int                `json:"count"`
+	Links []pages.BrokenLink `json:"links"`
+}
+
+// brokenLinksHandler serves the links in the content of m to pages or ids
+// that don't exist.
+func brokenLinksHandler(res http.ResponseWriter, req *http.Request, m *mount) {
+	responses := &m.responses
+	responses.Lock()
+	tag := etag(responses.Revision, brokenLinksPath)
+	resp := brokenLinksResp{Count: len(responses.Broken), Links: responses.Broken}
+	responses.Unlock()
+	if m.notModified(res, req, tag, time.Time{}) {
+		return
+	}
+
+	if resp.Links == nil {
+		resp.Links = []pages.BrokenLink{}
+	}
+	buf, err := json.Marshal(resp)
+	if err != nil {
+		log.Warnf("brokenLinksHandler: unexpected error: %#v\n", err)
+		writeError(res, http.StatusInternalServerError, apiError{
+			Code:    codeInternal,
+			Message: "Could not list the broken links",
+			Path:    brokenLinksPath,
+		})
+		return
+	}
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	res.Write(buf)
+}
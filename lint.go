@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/datasektionen/taitan/pages"
+	"github.com/datasektionen/taitan/redact"
+	"github.com/datasektionen/taitan/sanitize"
+)
+
+// lint prints the problems with the content directories dirs, HTML that is
+// removed when they are served and broken links, and exits, with status 1 if
+// there are any. The HTML allowed is that of the top level of cfg, which needn't
+// be valid for serving, e.g. without a content_url.
+func lint(dirs []string, cfg *Config, cfgErr error) {
+	if len(dirs) == 0 {
+		usage()
+	}
+	if cfg == nil {
+		fmt.Fprintln(os.Stderr, redact.String(cfgErr.Error()))
+		os.Exit(1)
+	}
+	setVerbosity(cfg.LogLevel)
+
+	policy, err := sanitize.New(cfg.HTMLElements, cfg.HTMLAttributes)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	problems := 0
+	for _, dir := range dirs {
+		validRoot(dir)
+		resps, err := pages.Load(false, dir, pages.Options{Policy: policy, Trusted: cfg.TrustedDirs})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		redirects := readJumpFile(filepath.Join(dir, cfg.Jumpfile))
+		broken, err := pages.CheckLinks(resps, func(p string) bool {
+			_, ok := redirects[p]
+			return ok
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		paths := make([]string, 0, len(resps))
+		for p := range resps {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			for _, w := range resps[p].Warnings {
+				fmt.Println(strings.TrimSuffix(dir, "/") + w)
+				problems++
+			}
+		}
+		for _, l := range broken {
+			fmt.Println(strings.TrimSuffix(dir, "/") + l.String())
+			problems++
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d problems\n", problems)
+	if problems > 0 {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/datasektionen/taitan/pages"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Route classes used as labels for request metrics.
+const (
+	routePage      = "page"
+	routeFuzzyfile = "fuzzyfile"
+	routeSearch    = "search"
+	routeLinks     = "broken_links"
+	routeRedirect  = "redirect"
+	routeWebhook   = "webhook"
+	routeInternal  = "internal" // /_health, /_ready and /metrics.
+	routeAsset     = "asset"    // /_assets/.
+	routePreflight = "preflight"
+	routeNotFound  = "not_found"
+)
+
+// metricsHandler serves the metrics at /metrics. It is created once, since
+// promhttp.Handler registers its own metrics every time it is called.
+var metricsHandler = promhttp.Handler()
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "taitan_http_requests_total",
+		Help: "Number of HTTP requests by route class and status code.",
+	}, []string{"route", "code"})
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "taitan_http_request_duration_seconds",
+		Help:    "Latency of HTTP requests by route class and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "code"})
+	notFoundTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "taitan_not_found_total",
+		Help: "Number of requests for pages that don't exist, by mount and top level directory of the path.",
+	}, []string{"mount", "bucket"})
+
+	reloadsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "taitan_reloads_total",
+		Help: "Number of content reloads by mount.",
+	}, []string{"mount"})
+	reloadFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "taitan_reload_failures_total",
+		Help: "Number of failed content reloads by mount.",
+	}, []string{"mount"})
+	reloadDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "taitan_reload_duration_seconds",
+		Help:    "Duration of content reloads, including fetching, by mount.",
+		Buckets: []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"mount"})
+	pagesLoaded = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "taitan_pages_loaded",
+		Help: "Number of loaded pages by mount and language.",
+	}, []string{"mount", "lang"})
+
+	gitDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "taitan_git_operation_duration_seconds",
+		Help:    "Duration of git operations by operation and result.",
+		Buckets: []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"operation", "result"})
+
+	brokenLinks = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "taitan_broken_links",
+		Help: "Number of links to pages or ids that don't exist by mount and what is missing.",
+	}, []string{"mount", "missing"})
+
+	darkmodeFetchesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "taitan_darkmode_fetches_total",
+		Help: "Number of requests to darkmode by mount and result.",
+	}, []string{"mount", "result"})
+	darkmodeStatus = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "taitan_darkmode",
+		Help: "The last known darkmode status by mount, 1 during reception.",
+	}, []string{"mount"})
+)
+
+// result returns the result label for err.
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// observeMetrics records the metrics of a request.
+func observeMetrics(rec *recorder, req *http.Request) {
+	code := strconv.Itoa(rec.status)
+	requestsTotal.WithLabelValues(rec.route, code).Inc()
+	requestDuration.WithLabelValues(rec.route, code).Observe(rec.duration.Seconds())
+}
+
+// notFoundBucket returns the top level directory of path if it is one of
+// resps, so that the number of buckets stays bounded, and "other" otherwise.
+func notFoundBucket(resps map[string]*pages.Page, path string) string {
+	dir := rootDir(path)
+	if _, ok := resps[dir]; ok {
+		return dir
+	}
+	return "other"
+}
+
+// observeReload records the metrics of a reload of m that started at start.
+func (m *mount) observeReload(start time.Time, err error) {
+	reloadsTotal.WithLabelValues(m.String()).Inc()
+	reloadDuration.WithLabelValues(m.String()).Observe(time.Since(start).Seconds())
+	if err != nil {
+		reloadFailuresTotal.WithLabelValues(m.String()).Inc()
+		return
+	}
+
+	m.responses.Lock()
+	langs := make(map[string]int)
+	for _, p := range m.responses.Resps {
+		for lang := range p.Bodies {
+			langs[lang]++
+		}
+	}
+	missing := map[string]int{pages.MissingPage: 0, pages.MissingAnchor: 0}
+	for _, l := range m.responses.Broken {
+		missing[l.Missing]++
+	}
+	m.responses.Unlock()
+	pagesLoaded.DeletePartialMatch(prometheus.Labels{"mount": m.String()})
+	for lang, n := range langs {
+		pagesLoaded.WithLabelValues(m.String(), lang).Set(float64(n))
+	}
+	for what, n := range missing {
+		brokenLinks.WithLabelValues(m.String(), what).Set(float64(n))
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// recorder is a http.ResponseWriter that remembers what was written to it,
+// together with what the handler found out about the request.
+type recorder struct {
+	http.ResponseWriter
+	status    int
+	bytes     int
+	duration  time.Duration
+	route     string // The route class of the request.
+	lang      string // The language of the response, if any.
+	requestID string
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter.
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// setRoute sets the route class of the request written to res.
+func setRoute(res http.ResponseWriter, route string) {
+	if r, ok := res.(*recorder); ok {
+		r.route = route
+	}
+}
+
+// setLang sets the language of the response written to res.
+func setLang(res http.ResponseWriter, lang string) {
+	if r, ok := res.(*recorder); ok {
+		r.lang = lang
+	}
+}
+
+// requestID returns the ID of req from the X-Request-ID header set by a
+// proxy, or a new random one.
+func requestID(req *http.Request) string {
+	if id := req.Header.Get("X-Request-ID"); id != "" && len(id) <= 128 {
+		return id
+	}
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// observed calls next with a recorder and then passes what was recorded to
+// each of observers.
+func observed(next http.Handler, observers ...func(*recorder, *http.Request)) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rec := &recorder{ResponseWriter: res, route: routePage, requestID: requestID(req)}
+		res.Header().Set("X-Request-ID", rec.requestID)
+		next.ServeHTTP(rec, req)
+		rec.duration = time.Since(start)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		for _, observe := range observers {
+			observe(rec, req)
+		}
+	})
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datasektionen/taitan/pages"
+	"github.com/datasektionen/taitan/sanitize"
+	"github.com/datasektionen/taitan/search"
+	log "github.com/sirupsen/logrus"
+)
+
+// mount is a content source served under a path prefix and/or hostname.
+type mount struct {
+	name         string   // Name of the mount in the configuration.
+	prefix       string   // Path prefix the content is served under, "/" for the root.
+	hosts        []string // Hostnames the content is served on, empty for any.
+	contentURL   string   // The repository to get content from.
+	contentDir   string   // Local directory to serve instead of cloning contentURL.
+	ref          string   // Branch or tag to check out, "" for the default branch.
+	defaultLang  string   // Language used when no lang parameter is passed.
+	darkmodeURL  string   // URL to darkmode, or "true" or "false".
+	jumpfile     string   // Path to the jumpfile, relative to the content root.
+	cors         corsPolicy
+	html         *sanitize.Policy
+	fuzzyBaseURL string   // Base URL of the hrefs in the fuzzyfile.
+	fuzzyAnchors int      // Include headings up to this level in the fuzzyfile.
+	cacheControl string   // Cache-Control header of successful responses.
+	assetURL     string   // URL the files of the content are served under.
+	trustedDirs  []string // Directories whose HTML isn't sanitized.
+	auth         gitAuth  // Credentials for contentURL.
+
+	responses Atomic     // Our parsed responses.
+	reload    sync.Mutex // Serializes fetching and reloading of the content.
+
+	darkmode struct {
+		mu     sync.Mutex
+		result bool
+		known  bool // Whether darkmode has ever been fetched.
+	}
+
+	status struct {
+		sync.Mutex
+		lastError    error     // Error of the last reload, nil if it succeeded.
+		failingSince time.Time // When reloads started failing.
+	}
+}
+
+// mounts are all content sources we serve.
+var mounts []*mount
+
+// loadMounts creates the mounts of cfg.
+func loadMounts(cfg *Config) ([]*mount, error) {
+	var ms []*mount
+	for _, mc := range cfg.mountConfigs() {
+		m := &mount{
+			name:        mc.Name,
+			prefix:      "/" + strings.Trim(mc.Prefix, "/"),
+			contentURL:  mc.ContentURL,
+			contentDir:  mc.ContentDir,
+			ref:         mc.Ref,
+			defaultLang: *mc.DefaultLang,
+			darkmodeURL: mc.DarkmodeURL,
+			jumpfile:    mc.Jumpfile,
+			cors: corsPolicy{
+				origins: mc.CORSOrigins,
+				maxAge:  mc.CORSMaxAge.Duration,
+			},
+			fuzzyBaseURL: strings.TrimSuffix(mc.FuzzyfileBaseURL, "/"),
+			fuzzyAnchors: *mc.FuzzyfileAnchors,
+			cacheControl: mc.CacheControl,
+			assetURL:     strings.TrimSuffix(mc.AssetBaseURL, "/") + assetsPath,
+			trustedDirs:  mc.TrustedDirs,
+			auth: gitAuth{
+				token:      mc.Token,
+				sshKey:     mc.SSHKeyFile,
+				knownHosts: mc.SSHKnownHostsFile,
+			},
+		}
+		var err error
+		m.html, err = sanitize.New(mc.HTMLElements, mc.HTMLAttributes)
+		if err != nil {
+			return nil, fmt.Errorf("mount %q: %w", m, err)
+		}
+		for _, h := range mc.Hosts {
+			m.hosts = append(m.hosts, strings.ToLower(h))
+		}
+		for _, h := range mc.CORSHeaders {
+			m.cors.headers = append(m.cors.headers, http.CanonicalHeaderKey(h))
+		}
+		for _, method := range mc.CORSMethods {
+			m.cors.methods = append(m.cors.methods, strings.ToUpper(method))
+		}
+		if _, _, err := m.remote(); err != nil {
+			return nil, fmt.Errorf("mount %q: %w", m, err)
+		}
+		for _, o := range ms {
+			// A clone must not end up in the directory of another mount.
+			if (m.contentDir == "" || o.contentDir == "") && filepath.Clean(o.root()) == filepath.Clean(m.root()) {
+				return nil, fmt.Errorf("mount %q: same content directory %q as mount %q", m, m.root(), o)
+			}
+			if o.prefix != m.prefix {
+				continue
+			}
+			if len(o.hosts) == 0 && len(m.hosts) == 0 {
+				return nil, fmt.Errorf("mount %q: same prefix as mount %q", m, o)
+			}
+			for _, h := range m.hosts {
+				if o.servesHost(h) {
+					return nil, fmt.Errorf("mount %q: same prefix and host %q as mount %q", m, h, o)
+				}
+			}
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+// splitList splits a comma- or space-separated list.
+func splitList(s string) []string {
+	return strings.FieldsFunc(s, func(c rune) bool { return c == ',' || c == ' ' })
+}
+
+// findMount returns the mount serving the request for host and path together
+// with the path inside that mount. Mounts on a matching host are preferred
+// over mounts on any host, and longer prefixes over shorter.
+func findMount(host, p string) (*mount, string) {
+	if h, _, ok := strings.Cut(host, ":"); ok {
+		host = h
+	}
+	host = strings.ToLower(host)
+	p = path.Clean("/" + p)
+
+	var best *mount
+	for _, m := range mounts {
+		if len(m.hosts) > 0 && !m.servesHost(host) {
+			continue
+		}
+		if m.prefix != "/" && p != m.prefix && !strings.HasPrefix(p, m.prefix+"/") {
+			continue
+		}
+		if best == nil || m.matchesBetter(best) {
+			best = m
+		}
+	}
+	if best == nil {
+		return nil, p
+	}
+	inner := "/" + strings.TrimPrefix(strings.TrimPrefix(p, best.prefix), "/")
+	return best, inner
+}
+
+// matchesBetter reports whether m is a more specific match than o, given that
+// both match a request.
+func (m *mount) matchesBetter(o *mount) bool {
+	if (len(m.hosts) > 0) != (len(o.hosts) > 0) {
+		return len(m.hosts) > 0
+	}
+	return len(m.prefix) > len(o.prefix)
+}
+
+// servesHost reports whether host is one of the hosts of m.
+func (m *mount) servesHost(host string) bool {
+	for _, h := range m.hosts {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns a name of the mount for log messages.
+func (m *mount) String() string {
+	if m.name == "" {
+		return strings.Join(m.hosts, ",") + m.prefix
+	}
+	return m.name
+}
+
+// url returns the URL of the page at p inside m with the query string query.
+func (m *mount) url(p, query string) string {
+	u := url.URL{Path: path.Join(m.prefix, p), RawQuery: query}
+	return u.String()
+}
+
+// load fetches and parses the content of m.
+func (m *mount) load() error {
+	m.reload.Lock()
+	defer m.reload.Unlock()
+
+	start := time.Now()
+	err := m.fetch()
+	if err == nil {
+		err = m.parse()
+	}
+	m.setStatus(err)
+	m.observeReload(start, err)
+	return err
+}
+
+// reloadContent parses the content of m from disk again.
+func (m *mount) reloadContent() error {
+	m.reload.Lock()
+	defer m.reload.Unlock()
+
+	start := time.Now()
+	err := m.parse()
+	m.setStatus(err)
+	m.observeReload(start, err)
+	return err
+}
+
+// setStatus records the result of a reload.
+func (m *mount) setStatus(err error) {
+	m.status.Lock()
+	defer m.status.Unlock()
+
+	if err == nil {
+		m.status.failingSince = time.Time{}
+	} else if m.status.lastError == nil {
+		m.status.failingSince = time.Now()
+	}
+	m.status.lastError = err
+}
+
+// parse parses the content of m and replaces the served responses.
+func (m *mount) parse() error {
+	isReception, err := m.getDarkmode()
+	if err != nil {
+		return fmt.Errorf("Could not get darkmode status: %w", err)
+	}
+	root := m.root()
+	log.WithFields(log.Fields{"mount": m, "Root": root}).Info("Our root directory")
+	resps, err := pages.Load(isReception, root, pages.Options{Policy: m.html, AssetURL: m.assetURL, Trusted: m.trustedDirs})
+	if err != nil {
+		return fmt.Errorf("Could not load pages: %w", err)
+	}
+	jumpfile := readJumpFile(filepath.Join(root, m.jumpfile))
+	broken, err := pages.CheckLinks(resps, func(p string) bool {
+		_, ok := jumpfile[p]
+		return ok
+	})
+	if err != nil {
+		return fmt.Errorf("Could not check links: %w", err)
+	}
+	log.WithField("Resps", resps).Debug("The parsed responses")
+	if n := warnings(resps) + len(broken); n > 0 {
+		log.WithFields(log.Fields{"mount": m, "warnings": n}).Info("Problems with the content, see taitan lint")
+	}
+	index := search.New(resps)
+	commit, err := revision(root)
+	if err != nil {
+		log.WithField("mount", m).Infoln("Could not get served commit:", err)
+	}
+
+	m.responses.Lock()
+	m.responses.Resps = resps
+	m.responses.Canonical = pages.CanonicalPaths(resps)
+	m.responses.Index = index
+	m.responses.Jumpfile = jumpfile
+	m.responses.Broken = broken
+	m.responses.Commit = commit
+	m.responses.Revision = revisionOf(commit, isReception)
+	m.responses.Cache = newEncodedCache()
+	m.responses.LoadedAt = time.Now()
+	m.responses.Modified = lastModified(resps)
+	m.responses.Unlock()
+	return nil
+}
+
+// lastModified returns the newest update time of the pages in resps, which
+// unlike the time they were loaded is the same across restarts and replicas.
+// It is zero if there are no pages.
+func lastModified(resps map[string]*pages.Page) time.Time {
+	var newest time.Time
+	for _, r := range resps {
+		for _, s := range r.UpdatedAt {
+			t, err := time.Parse(pages.ISO8601DateTime, s)
+			if err == nil && t.After(newest) {
+				newest = t
+			}
+		}
+	}
+	return newest
+}
+
+// warnings returns the number of problems with the content of resps.
+func warnings(resps map[string]*pages.Page) int {
+	n := 0
+	for _, r := range resps {
+		n += len(r.Warnings)
+	}
+	return n
+}
+
+// darkmodeClient requests the darkmode status, with a timeout so that a
+// darkmode that hangs can't hang reloads.
+var darkmodeClient = &http.Client{Timeout: 10 * time.Second}
+
+func (m *mount) getDarkmode() (bool, error) {
+	var darkmode bool
+	url := m.darkmodeURL
+	if url == "true" || url == "false" {
+		darkmode = url == "true"
+	} else {
+		var err error
+		darkmode, err = fetchDarkmode(url)
+		darkmodeFetchesTotal.WithLabelValues(m.String(), result(err)).Inc()
+		if err != nil {
+			// The last known status is kept, the failing reload is what
+			// makes the mount unready once it has failed for long enough.
+			return true, err
+		}
+		log.WithField("mount", m).Info("Darkmode status: ", darkmode)
+	}
+
+	// The lock isn't held during the request, so that e.g. /_ready doesn't
+	// wait for darkmode.
+	m.darkmode.mu.Lock()
+	m.darkmode.result, m.darkmode.known = darkmode, true
+	m.darkmode.mu.Unlock()
+	if darkmode {
+		darkmodeStatus.WithLabelValues(m.String()).Set(1)
+	} else {
+		darkmodeStatus.WithLabelValues(m.String()).Set(0)
+	}
+	return darkmode, nil
+}
+
+// fetchDarkmode requests the darkmode status from url.
+func fetchDarkmode(url string) (bool, error) {
+	res, err := darkmodeClient.Get(url)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	var darkmode bool
+	if err := json.NewDecoder(res.Body).Decode(&darkmode); err != nil {
+		return false, err
+	}
+	return darkmode, nil
+}
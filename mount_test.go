@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/datasektionen/taitan/pages"
+)
+
+var findmounttests = []struct {
+	host, path string
+	mount      string
+	inner      string
+}{
+	{"datasektionen.se", "/sektionen", "root", "/sektionen"},
+	{"datasektionen.se", "/", "root", "/"},
+	{"datasektionen.se", "/styrdokument", "styrdokument", "/"},
+	{"datasektionen.se", "/styrdokument/", "styrdokument", "/"},
+	{"datasektionen.se", "/styrdokument/stadgar", "styrdokument", "/stadgar"},
+	{"datasektionen.se", "/styrdokumentet", "root", "/styrdokumentet"},
+	{"datasektionen.se", "/styrdokument/../sektionen", "root", "/sektionen"},
+	{"datasektionen.se", "/styrdokument/reglemente/bilaga", "reglemente", "/bilaga"},
+	{"bawang.datasektionen.se", "/sektionen", "bawang", "/sektionen"},
+	{"bawang.datasektionen.se:8080", "/sektionen", "bawang", "/sektionen"},
+	{"Bawang.Datasektionen.SE", "/sektionen", "bawang", "/sektionen"},
+	{"bawang.datasektionen.se", "/styrdokument/stadgar", "bawang", "/styrdokument/stadgar"},
+	{"bawang.datasektionen.se", "/nyheter/idag", "bawang-nyheter", "/idag"},
+	{"localhost:5000", "/styrdokument", "styrdokument", "/"},
+}
+
+func TestFindMount(t *testing.T) {
+	defer func(ms []*mount) { mounts = ms }(mounts)
+	mounts = []*mount{
+		{name: "root", prefix: "/"},
+		{name: "styrdokument", prefix: "/styrdokument"},
+		{name: "reglemente", prefix: "/styrdokument/reglemente"},
+		{name: "bawang", prefix: "/", hosts: []string{"bawang.datasektionen.se"}},
+		{name: "bawang-nyheter", prefix: "/nyheter", hosts: []string{"bawang.datasektionen.se"}},
+	}
+	for _, tt := range findmounttests {
+		m, inner := findMount(tt.host, tt.path)
+		if m == nil {
+			t.Errorf("findMount(%q, %q) => nil, want %q", tt.host, tt.path, tt.mount)
+			continue
+		}
+		if m.name != tt.mount || inner != tt.inner {
+			t.Errorf("findMount(%q, %q) => %q, %q, want %q, %q", tt.host, tt.path, m.name, inner, tt.mount, tt.inner)
+		}
+	}
+}
+
+func TestFindMountNone(t *testing.T) {
+	defer func(ms []*mount) { mounts = ms }(mounts)
+	mounts = []*mount{
+		{name: "styrdokument", prefix: "/styrdokument"},
+		{name: "bawang", prefix: "/", hosts: []string{"bawang.datasektionen.se"}},
+	}
+	for _, p := range []string{"/", "/sektionen", "/styrdokumentet"} {
+		if m, _ := findMount("datasektionen.se", p); m != nil {
+			t.Errorf("findMount(%q, %q) => %q, want nil", "datasektionen.se", p, m.name)
+		}
+	}
+}
+
+var loadmountstests = []struct {
+	mounts []MountConfig
+	ok     bool
+}{
+	// The same repository at two refs gets two clones.
+	{[]MountConfig{
+		{Name: "a", Prefix: "/a", ContentURL: "https://github.com/datasektionen/content.git", Ref: "main"},
+		{Name: "b", Prefix: "/b", ContentURL: "https://github.com/datasektionen/content.git", Ref: "next"},
+	}, true},
+	{[]MountConfig{
+		{Name: "a", Prefix: "/a", ContentDir: "/srv/content"},
+		{Name: "b", Prefix: "/b", ContentDir: "/srv/content"},
+	}, true},
+	{[]MountConfig{
+		{Name: "a", Prefix: "/a", ContentURL: "https://github.com/datasektionen/content.git"},
+		{Name: "b", Prefix: "/b", ContentDir: "a"},
+	}, false},
+	{[]MountConfig{
+		{Name: "a", Prefix: "/", ContentDir: "/srv/a"},
+		{Name: "b", Prefix: "/", ContentDir: "/srv/b"},
+	}, false},
+}
+
+func TestLoadMounts(t *testing.T) {
+	for _, tt := range loadmountstests {
+		cfg := &Config{Mounts: tt.mounts}
+		for i := range cfg.Mounts {
+			cfg.Mounts[i].setDefaults()
+			cfg.Mounts[i].DefaultLang = new(string)
+		}
+		_, err := loadMounts(cfg)
+		if (err == nil) != tt.ok {
+			t.Errorf("loadMounts(%+v) => error %v, want ok %v", tt.mounts, err, tt.ok)
+		}
+	}
+}
+
+func TestGetDarkmodeKeepsStatus(t *testing.T) {
+	fail := false
+	srv := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if fail {
+			http.Error(res, "down", http.StatusBadGateway)
+			return
+		}
+		res.Write([]byte("false"))
+	}))
+	defer srv.Close()
+	m := &mount{name: "root", darkmodeURL: srv.URL}
+	if _, err := m.getDarkmode(); err != nil {
+		t.Fatal(err)
+	}
+	fail = true
+	if _, err := m.getDarkmode(); err == nil {
+		t.Fatal("getDarkmode() with darkmode down => no error")
+	}
+	if !m.darkmode.known || m.darkmode.result {
+		t.Errorf("getDarkmode() with darkmode down => known %v, result %v, want true, false", m.darkmode.known, m.darkmode.result)
+	}
+}
+
+func TestLastModified(t *testing.T) {
+	resps := map[string]*pages.Page{
+		"/":          {UpdatedAt: pages.LangLookup{"sv": "2015-11-08T23:14:30Z", "en": "2016-01-02T10:00:00Z"}},
+		"/sektionen": {UpdatedAt: pages.LangLookup{"sv": "2015-12-24T15:00:00Z"}},
+	}
+	want := time.Date(2016, 1, 2, 10, 0, 0, 0, time.UTC)
+	if got := lastModified(resps); !got.Equal(want) {
+		t.Errorf("lastModified => %v, want %v", got, want)
+	}
+	if got := lastModified(nil); !got.IsZero() {
+		t.Errorf("lastModified(nil) => %v, want zero", got)
+	}
+}
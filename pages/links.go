@@ -0,0 +1,344 @@
+package pages
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/datasektionen/taitan/suggest"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// MissingClass is the class of links to internal pages, or ids on them, that
+// don't exist.
+const MissingClass = "missing"
+
+// assetExts are the extensions of the files in the content that are served
+// as assets, and may be linked to.
+var assetExts = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true,
+	".avif": true, ".svg": true, ".ico": true, ".pdf": true, ".txt": true,
+	".csv": true, ".mp3": true, ".ogg": true, ".mp4": true, ".webm": true,
+	".doc": true, ".docx": true, ".xls": true, ".xlsx": true, ".ppt": true,
+	".pptx": true, ".odt": true, ".ods": true, ".odp": true, ".zip": true,
+}
+
+// IsAsset reports whether the file at the path p in the content is served
+// as an asset. Markdown, meta.toml and hidden files never are.
+func IsAsset(p string) bool {
+	for _, part := range strings.Split(p, "/") {
+		if strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+	return assetExts[strings.ToLower(path.Ext(p))]
+}
+
+// rewriteLinks makes the links and images of the HTML body of the page at
+// the path page, with its files in the content directory root, work where
+// the page is served. Relative links are resolved against the page, as they
+// are written relative to its markdown file, so that "../stadgar" on
+// /sektionen/om-oss becomes /sektionen/stadgar. Images and links to assets
+// are rewritten to their URLs under assetURL.
+func rewriteLinks(body, root, page, assetURL string) (string, error) {
+	nodes, err := parseFragment(body)
+	if err != nil {
+		return "", err
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.DataAtom {
+		case atom.A:
+			rewriteAttr(n, "href", root, page, assetURL, false)
+		case atom.Img:
+			rewriteAttr(n, "src", root, page, assetURL, true)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+	return renderFragment(nodes)
+}
+
+// rewriteAttr rewrites the URL in the attribute key of n, as described by
+// rewriteLinks. Images are rewritten to assets also if they don't exist, since
+// they can't be pages.
+func rewriteAttr(n *html.Node, key, root, page, assetURL string, image bool) {
+	for i, a := range n.Attr {
+		if a.Key != key {
+			continue
+		}
+		u, err := url.Parse(a.Val)
+		if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+			return
+		}
+		p := u.Path
+		relative := !path.IsAbs(p)
+		if relative {
+			p = path.Join(page, p)
+		}
+		switch {
+		case IsAsset(p) && (image || isFile(filepath.Join(root, filepath.FromSlash(p)))):
+			n.Attr[i].Val = assetURL + (&url.URL{Path: p, Fragment: u.Fragment}).String()
+		case relative:
+			u.Path = p
+			n.Attr[i].Val = u.String()
+		}
+		return
+	}
+}
+
+func isFile(name string) bool {
+	fi, err := os.Stat(name)
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// What a broken link is missing.
+const (
+	MissingPage   = "page"
+	MissingAnchor = "anchor"
+)
+
+// A BrokenLink is a link to an internal page, or an id on one, that doesn't
+// exist.
+type BrokenLink struct {
+	Page    string `json:"page"`    // Path of the page the link is on.
+	Lang    string `json:"lang"`    // Language of the body or sidebar the link is in.
+	Sidebar bool   `json:"sidebar"` // Whether the link is in the sidebar rather than the body.
+	Href    string `json:"href"`
+	Missing string `json:"missing"` // MissingPage or MissingAnchor.
+}
+
+// File returns the path of the markdown file the link is in.
+func (l BrokenLink) File() string {
+	name := "body"
+	if l.Sidebar {
+		name = "sidebar"
+	}
+	if l.Lang != "" {
+		name += "_" + l.Lang
+	}
+	return path.Join(l.Page, name+".md")
+}
+
+func (l BrokenLink) String() string {
+	return fmt.Sprintf("%s: broken link to %s, no such %s", l.File(), l.Href, l.Missing)
+}
+
+// CanonicalPaths returns the paths of pages by their canonical form, see
+// suggest.Canonical, which requests for paths that aren't pages are
+// redirected by. Forms shared by several paths are left out, since they
+// don't say which is meant.
+func CanonicalPaths(pages map[string]*Page) map[string]string {
+	canonical := make(map[string]string, len(pages))
+	shared := make(map[string]bool)
+	for p := range pages {
+		c := suggest.Canonical(p)
+		if _, ok := canonical[c]; ok {
+			shared[c] = true
+		}
+		canonical[c] = p
+	}
+	for c := range shared {
+		delete(canonical, c)
+	}
+	return canonical
+}
+
+// CheckLinks finds the links in the bodies and sidebars of pages to internal
+// pages that neither are in pages, nor are redirected to one by their
+// canonical form, nor exist according to exists, which e.g. knows the
+// redirects of the jumpfile. It also finds links to ids that aren't on the
+// page linked to, in the language of the lang parameter of the link, or else
+// of the link, if the page is in it. It adds MissingClass to them and
+// returns them, sorted by page.
+func CheckLinks(pages map[string]*Page, exists func(p string) bool) ([]BrokenLink, error) {
+	canonical := CanonicalPaths(pages)
+	paths := make([]string, 0, len(pages))
+	for p := range pages {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	// A fragment is a parsed body or sidebar.
+	type fragment struct {
+		lang    string
+		sidebar bool
+		nodes   []*html.Node
+	}
+	parsed := make(map[string][]fragment)
+	// ids[p][lang] are the ids of the elements on the page at p in lang.
+	ids := make(map[string]map[string]map[string]bool)
+	for _, p := range paths {
+		ids[p] = make(map[string]map[string]bool)
+		for _, sidebar := range []bool{false, true} {
+			htmls := pages[p].Bodies
+			if sidebar {
+				htmls = pages[p].Sidebars
+			}
+			for _, lang := range sortedKeys(htmls) {
+				nodes, err := parseFragment(htmls[lang])
+				if err != nil {
+					return nil, err
+				}
+				parsed[p] = append(parsed[p], fragment{lang, sidebar, nodes})
+				if ids[p][lang] == nil {
+					ids[p][lang] = make(map[string]bool)
+				}
+				for _, n := range nodes {
+					collectIDs(n, ids[p][lang])
+				}
+			}
+		}
+	}
+
+	// missing returns what the link href in lang on the page at p is
+	// missing, or "" if it isn't broken.
+	missing := func(p, lang, href string) string {
+		u, err := url.Parse(href)
+		if err != nil || u.Scheme != "" || u.Host != "" || IsAsset(u.Path) {
+			return ""
+		}
+		target := p
+		if u.Path != "" {
+			if !path.IsAbs(u.Path) {
+				return ""
+			}
+			target = path.Clean(u.Path)
+		}
+		if _, ok := pages[target]; !ok {
+			p, ok := canonical[suggest.Canonical(target)]
+			switch {
+			case ok:
+				target = p
+			case exists(target):
+				return ""
+			default:
+				return MissingPage
+			}
+		}
+		if u.Fragment == "" {
+			return ""
+		}
+		if l := u.Query().Get("lang"); l != "" {
+			lang = l
+		}
+		if langIDs, ok := ids[target][lang]; ok {
+			if langIDs[u.Fragment] {
+				return ""
+			}
+			return MissingAnchor
+		}
+		for _, langIDs := range ids[target] {
+			if langIDs[u.Fragment] {
+				return ""
+			}
+		}
+		return MissingAnchor
+	}
+
+	var broken []BrokenLink
+	for _, p := range paths {
+		for _, f := range parsed[p] {
+			marked := false
+			var walk func(*html.Node)
+			walk = func(n *html.Node) {
+				if n.DataAtom == atom.A {
+					href := attr(n, "href")
+					if m := missing(p, f.lang, href); m != "" {
+						broken = append(broken, BrokenLink{Page: p, Lang: f.lang, Sidebar: f.sidebar, Href: href, Missing: m})
+						addClass(n, MissingClass)
+						marked = true
+					}
+				}
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					walk(c)
+				}
+			}
+			for _, n := range f.nodes {
+				walk(n)
+			}
+			if !marked {
+				continue
+			}
+			out, err := renderFragment(f.nodes)
+			if err != nil {
+				return nil, err
+			}
+			if f.sidebar {
+				pages[p].Sidebars[f.lang] = out
+			} else {
+				pages[p].Bodies[f.lang] = out
+			}
+		}
+	}
+	return broken, nil
+}
+
+// collectIDs adds the ids of n and its descendants, and the names of
+// anchors, to ids.
+func collectIDs(n *html.Node, ids map[string]bool) {
+	if id := attr(n, "id"); id != "" {
+		ids[id] = true
+	}
+	if name := attr(n, "name"); name != "" && n.DataAtom == atom.A {
+		ids[name] = true
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		collectIDs(c, ids)
+	}
+}
+
+func sortedKeys(l LangLookup) []string {
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseFragment parses the HTML body of a page.
+func parseFragment(body string) ([]*html.Node, error) {
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	return html.ParseFragment(strings.NewReader(body), context)
+}
+
+func renderFragment(nodes []*html.Node) (string, error) {
+	var b strings.Builder
+	for _, n := range nodes {
+		if err := html.Render(&b, n); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func addClass(n *html.Node, class string) {
+	for i, a := range n.Attr {
+		if a.Key == "class" {
+			n.Attr[i].Val = strings.TrimSpace(a.Val + " " + class)
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: "class", Val: class})
+}
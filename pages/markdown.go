@@ -0,0 +1,209 @@
+package pages
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/datasektionen/taitan/anchor"
+	"github.com/russross/blackfriday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// Markdown renderers, selected with the markdown field of meta.toml.
+const (
+	// MarkdownLegacy is blackfriday with tables and fenced code.
+	MarkdownLegacy = "legacy"
+	// MarkdownCommonMark is CommonMark with footnotes, definition lists,
+	// strikethrough, task lists, autolinks and typographic quotes.
+	MarkdownCommonMark = "commonmark"
+)
+
+// commonMark renders CommonMark with typographic quotes in the style of a
+// language: Swedish ”citat” and English “quotes”.
+var commonMark = map[string]goldmark.Markdown{
+	"sv": newCommonMark(map[extension.TypographicPunctuation]string{
+		extension.LeftDoubleQuote:  "&rdquo;",
+		extension.RightDoubleQuote: "&rdquo;",
+		extension.LeftSingleQuote:  "&rsquo;",
+		extension.RightSingleQuote: "&rsquo;",
+	}),
+	"en": newCommonMark(nil),
+}
+
+func newCommonMark(quotes map[extension.TypographicPunctuation]string) goldmark.Markdown {
+	return goldmark.New(
+		goldmark.WithExtensions(
+			extension.GFM,
+			extension.Footnote,
+			extension.DefinitionList,
+			extension.NewTypographer(extension.WithTypographicSubstitutions(quotes)),
+		),
+		// Raw HTML is passed through, as with blackfriday.
+		goldmark.WithRendererOptions(html.WithUnsafe(), html.WithXHTML()),
+	)
+}
+
+// render renders markdown in lang to HTML with the markdown renderer.
+// Pages without a language are in Swedish.
+func render(markdown []byte, renderer, lang string) (string, error) {
+	if renderer != MarkdownCommonMark {
+		// Use standard HTML rendering.
+		r := blackfriday.HtmlRenderer(blackfriday.HTML_USE_XHTML, "", "")
+		return string(blackfriday.MarkdownOptions(markdown, r, blackfriday.Options{
+			Extensions: blackfriday.EXTENSION_TABLES | blackfriday.EXTENSION_FENCED_CODE,
+		})), nil
+	}
+
+	md, ok := commonMark[lang]
+	if !ok && lang == "" {
+		md = commonMark["sv"]
+	} else if !ok {
+		md = commonMark["en"]
+	}
+	var buf bytes.Buffer
+	if err := md.Convert(markdown, &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// DiffMarkdown writes how the HTML of the markdown files under root would
+// change if they were rendered with MarkdownCommonMark instead of
+// MarkdownLegacy, and returns the number of files that would change.
+func DiffMarkdown(w io.Writer, root string) (int, error) {
+	changed := 0
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name()[0] == '.' && path != root {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !(bodyReg.MatchString(d.Name()) || sidebarReg.MatchString(d.Name())) {
+			return nil
+		}
+
+		lang := ""
+		if match := bodyReg.FindStringSubmatch(d.Name()); match != nil {
+			lang = match[1][1:]
+		} else if match := sidebarReg.FindStringSubmatch(d.Name()); match != nil {
+			lang = match[1][1:]
+		}
+		legacy, err := diffHTML(root, path, MarkdownLegacy, lang)
+		if err != nil {
+			return err
+		}
+		commonmark, err := diffHTML(root, path, MarkdownCommonMark, lang)
+		if err != nil {
+			return err
+		}
+		a, b := lines(legacy), lines(commonmark)
+		if slices.Equal(a, b) {
+			return nil
+		}
+
+		changed++
+		rel, _ := filepath.Rel(root, path)
+		fmt.Fprintf(w, "--- %s (%s)\n+++ %s (%s)\n", rel, MarkdownLegacy, rel, MarkdownCommonMark)
+		writeDiff(w, a, b)
+		return nil
+	})
+	return changed, err
+}
+
+// diffHTML renders the markdown file at path in lang with renderer, with ids
+// on its headings.
+func diffHTML(root, path, renderer, lang string) (string, error) {
+	body, _, err := toHTML(false, root, path, Meta{Markdown: renderer}, Options{}, lang)
+	if err != nil {
+		return "", err
+	}
+	out, err := anchor.SetIDs(body)
+	if err != nil {
+		return "", err
+	}
+	return out[0], nil
+}
+
+// lines returns the lines of the HTML s that aren't blank, which are the
+// ones that matter.
+func lines(s string) []string {
+	var ls []string
+	for _, l := range strings.Split(s, "\n") {
+		if strings.TrimSpace(l) != "" {
+			ls = append(ls, l)
+		}
+	}
+	return ls
+}
+
+// diffContext is the number of unchanged lines shown around changes.
+const diffContext = 2
+
+// writeDiff writes the lines removed from a and added in b, prefixed with -
+// and +, together with the unchanged lines around them.
+func writeDiff(w io.Writer, a, b []string) {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+	}
+	var diff []line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			diff = append(diff, line{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			diff = append(diff, line{'-', a[i]})
+			i++
+		default:
+			diff = append(diff, line{'+', b[j]})
+			j++
+		}
+	}
+
+	// Show unchanged lines only close to changes.
+	show := make([]bool, len(diff))
+	for k, l := range diff {
+		if l.op == ' ' {
+			continue
+		}
+		for c := max(k-diffContext, 0); c <= min(k+diffContext, len(diff)-1); c++ {
+			show[c] = true
+		}
+	}
+	for k, l := range diff {
+		if !show[k] {
+			continue
+		}
+		if k > 0 && !show[k-1] {
+			fmt.Fprintln(w, "@@")
+		}
+		fmt.Fprintf(w, "%c%s\n", l.op, l.text)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -13,14 +14,16 @@ import (
 	"time"
 
 	"github.com/datasektionen/taitan/anchor"
+	"github.com/datasektionen/taitan/highlight"
+	"github.com/datasektionen/taitan/sanitize"
 
 	"github.com/BurntSushi/toml"
-	"github.com/russross/blackfriday"
 	log "github.com/sirupsen/logrus"
 )
 
 type LangLookup map[string]string
 type LangAnchorLookup map[string][]anchor.Anchor
+type LangTOCLookup map[string][]*anchor.TOCEntry
 
 type Page struct {
 	Titles    LangLookup       // Human-readable title.
@@ -34,6 +37,12 @@ type Page struct {
 	Sort      *int             // The order that the tab should appear in on the page
 	Expanded  bool             // Should the Nav-tree rooted in this node always be expanded one step when loaded?
 	Anchors   LangAnchorLookup // The list of anchors to headers in the body.
+	Color     string           // Color of the page in the fuzzyfile.
+
+	TOCs        LangTOCLookup // Table of contents of the body.
+	SidebarTOCs LangTOCLookup // Table of contents of the sidebar.
+
+	Warnings []string // Problems with the content, such as removed HTML.
 }
 
 // Node is a recursive node in a page tree.
@@ -56,11 +65,24 @@ type Meta struct {
 	Sort      *int       `toml:"sort"`
 	Expanded  bool       `toml:"expanded"`
 	Sensitive bool       `toml:"sensitive"`
+	Color     string     `toml:"color"`
+	Markdown  string     `toml:"markdown"`  // Markdown renderer, MarkdownLegacy by default.
+	Highlight bool       `toml:"highlight"` // Highlight the syntax of code blocks, true by default.
+
+	TOC        TOCLevels `toml:"toc"`
+	SidebarTOC TOCLevels `toml:"sidebar_toc"`
+}
+
+// TOCLevels are the levels of the headings in a table of contents.
+type TOCLevels struct {
+	Min int `toml:"min_level"`
+	Max int `toml:"max_level"`
 }
 
 const (
-	metaFile        = "meta.toml"
-	iso8601DateTime = "2006-01-02T15:04:05Z"
+	metaFile = "meta.toml"
+	// ISO8601DateTime is the format of the times in Page.UpdatedAt.
+	ISO8601DateTime = "2006-01-02T15:04:05Z"
 )
 
 var (
@@ -146,8 +168,26 @@ func (n *Node) Num() int {
 	return sum
 }
 
+// Options are how the pages are rendered.
+type Options struct {
+	Policy   *sanitize.Policy // Sanitize the HTML with, nil for not at all.
+	AssetURL string           // URL the files of the content are served under.
+	Trusted  []string         // Directories, with their subdirectories, not to sanitize.
+}
+
+// trusted reports whether the HTML of the page at p is served unsanitized.
+func (opts Options) trusted(p string) bool {
+	for _, dir := range opts.Trusted {
+		dir = path.Clean("/" + dir)
+		if p == dir || dir == "/" || strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 // Load intializes a root directory and serves all sub-folders.
-func Load(isReception bool, root string) (map[string]*Page, error) {
+func Load(isReception bool, root string, opts Options) (map[string]*Page, error) {
 	var dirs []string
 	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
 		// We only search for article directories.
@@ -165,7 +205,7 @@ func Load(isReception bool, root string) (map[string]*Page, error) {
 	if err != nil {
 		return nil, err
 	}
-	return parseDirs(isReception, root, dirs)
+	return parseDirs(isReception, root, dirs, opts)
 }
 
 // stripRoot removes root level of a directory.
@@ -177,10 +217,10 @@ func stripRoot(root string, dir string) string {
 
 // parseDirs parses each directory into a response. Returns a map from requested
 // urls into responses.
-func parseDirs(isReception bool, root string, dirs []string) (map[string]*Page, error) {
+func parseDirs(isReception bool, root string, dirs []string, opts Options) (map[string]*Page, error) {
 	pages := make(map[string]*Page)
 	for _, dir := range dirs {
-		r, err := parseDir(isReception, root, dir)
+		r, err := parseDir(isReception, root, dir, opts)
 		if err != nil {
 			log.Warnln(err)
 			return nil, err
@@ -197,38 +237,117 @@ func parseDirs(isReception bool, root string, dirs []string) (map[string]*Page,
 	return pages, nil
 }
 
-// toHTML reads a markdown file and returns a HTML string.
-func toHTML(isReception bool, filename string) (string, error) {
+// toHTML reads a markdown file in lang in the content directory root and
+// returns a HTML string, rendered as meta and opts say, together with what
+// the sanitizing removed. The headings don't have ids yet, see setIDs.
+func toHTML(isReception bool, root, filename string, meta Meta, opts Options, lang string) (string, []sanitize.Removal, error) {
 	rawMarkdown, err := os.ReadFile(filename)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 	t, err := template.New("").Parse(string(rawMarkdown))
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 	var filteredMarkdown bytes.Buffer
 	if err := t.Execute(&filteredMarkdown, map[string]any{"reception": isReception}); err != nil {
-		return "", err
-	}
-	// Use standard HTML rendering.
-	renderer := blackfriday.HtmlRenderer(blackfriday.HTML_USE_XHTML, "", "")
-	// Parse markdown where all id's are created from the values inside
-	// the element tag.
-	html := blackfriday.MarkdownOptions(filteredMarkdown.Bytes(), renderer, blackfriday.Options{
-		Extensions: blackfriday.EXTENSION_AUTO_HEADER_IDS | blackfriday.EXTENSION_TABLES | blackfriday.EXTENSION_FENCED_CODE,
-	})
-	return string(html), nil
+		return "", nil, err
+	}
+	html, err := render(filteredMarkdown.Bytes(), meta.Markdown, lang)
+	if err != nil {
+		return "", nil, err
+	}
+	dir := stripRoot(root, filepath.Dir(filename))
+	var removed []sanitize.Removal
+	if opts.Policy != nil && !opts.trusted(dir) {
+		html, removed, err = opts.Policy.Sanitize(html)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	html, err = rewriteLinks(html, root, dir, opts.AssetURL)
+	if err != nil {
+		return "", nil, err
+	}
+	if meta.Highlight {
+		html, err = highlight.Code(html)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return html, removed, nil
+}
+
+// readMeta parses the meta data of a directory from its toml file.
+func readMeta(dir string) (Meta, error) {
+	metaPath := filepath.Join(dir, metaFile)
+	var meta = Meta{
+		Sort:       nil, // all pages without a sort-tag should be after the pages with a sort-tag, but should keep their internal order
+		Expanded:   false,
+		Highlight:  true,
+		TOC:        TOCLevels{Min: 1, Max: 6},
+		SidebarTOC: TOCLevels{Min: 1, Max: 6},
+	}
+	var metaMap = make(map[string]any)
+	if _, err := toml.DecodeFile(metaPath, &meta); err != nil {
+		return Meta{}, err
+	}
+	if _, err := toml.DecodeFile(metaPath, &metaMap); err != nil {
+		return Meta{}, err
+	}
+	switch meta.Markdown {
+	case "", MarkdownLegacy, MarkdownCommonMark:
+	default:
+		return Meta{}, fmt.Errorf("%s: markdown must be %q or %q, not %q", metaPath, MarkdownLegacy, MarkdownCommonMark, meta.Markdown)
+	}
+	for _, toc := range []struct {
+		name   string
+		levels TOCLevels
+	}{{"toc", meta.TOC}, {"sidebar_toc", meta.SidebarTOC}} {
+		if err := toc.levels.validate(); err != nil {
+			return Meta{}, fmt.Errorf("%s: %s: %w", metaPath, toc.name, err)
+		}
+	}
+	return meta, nil
+}
+
+// validate checks that l are levels of headings, with the minimum at most
+// the maximum, since the table of contents is empty otherwise.
+func (l TOCLevels) validate() error {
+	if l.Min < 1 || l.Max > 6 {
+		return fmt.Errorf("levels must be between 1 and 6, not %d and %d", l.Min, l.Max)
+	}
+	if l.Min > l.Max {
+		return fmt.Errorf("min_level %d is greater than max_level %d", l.Min, l.Max)
+	}
+	return nil
 }
 
 // parseDir creates a response for a directory.
-func parseDir(isReception bool, root, dir string) (*Page, error) {
+func parseDir(isReception bool, root, dir string, opts Options) (*Page, error) {
 	log.WithField("dir", dir).Debug("Parsing directory:")
 
+	meta, err := readMeta(dir)
+	if err != nil {
+		return nil, err
+	}
+	if meta.Sensitive && isReception {
+		return nil, nil
+	}
+
 	bodies := make(LangLookup)
 	sidebars := make(LangLookup)
 	commitTimes := make(LangLookup)
 	anchorsLists := make(LangAnchorLookup)
+	sidebarAnchors := make(LangAnchorLookup)
+	var warnings []string
+	warn := func(file string, removed []sanitize.Removal) {
+		for _, r := range removed {
+			w := fmt.Sprintf("%s: removed %s", filepath.Join(stripRoot(root, dir), file), r)
+			log.WithField("dir", dir).Debug(w)
+			warnings = append(warnings, w)
+		}
+	}
 
 	entries, err := os.ReadDir(dir)
 
@@ -244,24 +363,20 @@ func parseDir(isReception bool, root, dir string) (*Page, error) {
 			if len(match) > 1 && len(match[1]) > 0 {
 				lang = string(match[1][1:])
 			}
-			bodies[lang], err = toHTML(isReception, entryPath)
+			var removed []sanitize.Removal
+			bodies[lang], removed, err = toHTML(isReception, root, entryPath, meta, opts, lang)
 			log.WithField("body", bodies[lang]).Debug("HTML of body_" + lang + ".md")
 
 			if err != nil {
 				return nil, err
 			}
+			warn(entry.Name(), removed)
 
 			commitTime, err := getCommitTime(root, entryPath)
 			if err != nil {
-				commitTimes[lang] = time.Now().Format(iso8601DateTime)
+				commitTimes[lang] = time.Now().Format(ISO8601DateTime)
 			} else {
-				commitTimes[lang] = commitTime.Format(iso8601DateTime)
-			}
-
-			// Parse anchors in the body.
-			anchorsLists[lang], err = anchor.Anchors(bodies[lang])
-			if err != nil {
-				return nil, err
+				commitTimes[lang] = commitTime.Format(ISO8601DateTime)
 			}
 		}
 
@@ -270,30 +385,39 @@ func parseDir(isReception bool, root, dir string) (*Page, error) {
 			if len(match) > 1 && len(match[1]) > 0 {
 				lang = string(match[1][1:])
 			}
-			sidebars[lang], err = toHTML(isReception, entryPath)
+			var removed []sanitize.Removal
+			sidebars[lang], removed, err = toHTML(isReception, root, entryPath, meta, opts, lang)
 			log.WithField("sidebar", sidebars[lang]).Debug("HTML of sidebar" + lang + ".md")
 			if err != nil {
 				return nil, err
 			}
+			warn(entry.Name(), removed)
 		}
 	}
 
-	// Parse meta data from a toml file.
-	metaPath := filepath.Join(dir, metaFile)
-	var meta = Meta{
-		Sort:     nil, // all pages without a sort-tag should be after the pages with a sort-tag, but should keep their internal order
-		Expanded: false,
-	}
-	var metaMap = make(map[string]any)
-	if _, err := toml.DecodeFile(metaPath, &meta); err != nil {
+	if err := setIDs(bodies, sidebars); err != nil {
 		return nil, err
 	}
-	if _, err := toml.DecodeFile(metaPath, &metaMap); err != nil {
-		return nil, err
+	for lang, body := range bodies {
+		anchorsLists[lang], err = anchor.Anchors(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for lang, sidebar := range sidebars {
+		sidebarAnchors[lang], err = anchor.Anchors(sidebar)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if meta.Sensitive && isReception {
-		return nil, nil
+	tocs := make(LangTOCLookup)
+	for lang, anchs := range anchorsLists {
+		tocs[lang] = anchor.TOC(anchs, meta.TOC.Min, meta.TOC.Max)
+	}
+	sidebarTOCs := make(LangTOCLookup)
+	for lang, anchs := range sidebarAnchors {
+		sidebarTOCs[lang] = anchor.TOC(anchs, meta.SidebarTOC.Min, meta.SidebarTOC.Max)
 	}
 
 	return &Page{
@@ -307,9 +431,49 @@ func parseDir(isReception bool, root, dir string) (*Page, error) {
 		Anchors:   anchorsLists,
 		Expanded:  meta.Expanded,
 		Sort:      meta.Sort,
+		Color:     meta.Color,
+
+		TOCs:        tocs,
+		SidebarTOCs: sidebarTOCs,
+
+		Warnings: warnings,
 	}, nil
 }
 
+// setIDs gives the headings of the bodies and sidebars ids, which are unique
+// on the page in each language.
+func setIDs(bodies, sidebars LangLookup) error {
+	langs := make(map[string]bool)
+	for lang := range bodies {
+		langs[lang] = true
+	}
+	for lang := range sidebars {
+		langs[lang] = true
+	}
+	for lang := range langs {
+		var in []string
+		body, hasBody := bodies[lang]
+		if hasBody {
+			in = append(in, body)
+		}
+		sidebar, hasSidebar := sidebars[lang]
+		if hasSidebar {
+			in = append(in, sidebar)
+		}
+		out, err := anchor.SetIDs(in...)
+		if err != nil {
+			return err
+		}
+		if hasBody {
+			bodies[lang], out = out[0], out[1:]
+		}
+		if hasSidebar {
+			sidebars[lang] = out[0]
+		}
+	}
+	return nil
+}
+
 // getCommitTime returns last commit time for a file.
 func getCommitTime(root string, filePath string) (time.Time, error) {
 	gitDir := fmt.Sprintf("--git-dir=%s/.git", root)
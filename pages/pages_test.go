@@ -2,6 +2,10 @@ package pages
 
 import (
 	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -25,6 +29,29 @@ func TestStripRoot(t *testing.T) {
 	}
 }
 
+var trustedtests = []struct {
+	trusted []string
+	in      string
+	out     bool
+}{
+	{nil, "/sektionen", false},
+	{[]string{"/sektionen"}, "/sektionen", true},
+	{[]string{"/sektionen"}, "/sektionen/historia", true},
+	{[]string{"sektionen/"}, "/sektionen/historia", true},
+	{[]string{"/sektionen"}, "/sektionenhistoria", false},
+	{[]string{"/sektionen/historia"}, "/sektionen", false},
+	{[]string{"/"}, "/sektionen", true},
+}
+
+func TestTrusted(t *testing.T) {
+	for _, tt := range trustedtests {
+		got := Options{Trusted: tt.trusted}.trusted(tt.in)
+		if tt.out != got {
+			t.Errorf("trusted(%q) with %q => %v, want %v", tt.in, tt.trusted, got, tt.out)
+		}
+	}
+}
+
 var getCommitTimetests = []struct {
 	in, in2 string
 	out     time.Time
@@ -48,12 +75,12 @@ func TestGetCommitTime(t *testing.T) {
 var toHTMLtests = []struct {
 	in, out string
 }{
-	{"test/body.md", "<h1 id=\"id-test\">Id test</h1>\n"},
+	{"test/body.md", "<h1>Id test</h1>\n"},
 }
 
 func TestToHTML(t *testing.T) {
 	for _, tt := range toHTMLtests {
-		got, err := toHTML(false, tt.in)
+		got, _, err := toHTML(false, "test", tt.in, Meta{Markdown: MarkdownLegacy}, Options{}, "")
 		if err != nil {
 			log.Fatalln(err)
 		}
@@ -62,3 +89,168 @@ func TestToHTML(t *testing.T) {
 		}
 	}
 }
+
+func TestSetIDs(t *testing.T) {
+	bodies := LangLookup{"sv": "<h2>Kontakt</h2>", "en": "<h2>Contact</h2>"}
+	sidebars := LangLookup{"sv": "<h2>Kontakt</h2>", "fi": "<h2>Yhteystiedot</h2>"}
+	if err := setIDs(bodies, sidebars); err != nil {
+		t.Fatalf("setIDs => error %v", err)
+	}
+	wantBodies := LangLookup{"sv": `<h2 id="kontakt">Kontakt</h2>`, "en": `<h2 id="contact">Contact</h2>`}
+	wantSidebars := LangLookup{"sv": `<h2 id="kontakt-2">Kontakt</h2>`, "fi": `<h2 id="yhteystiedot">Yhteystiedot</h2>`}
+	if !reflect.DeepEqual(bodies, wantBodies) || !reflect.DeepEqual(sidebars, wantSidebars) {
+		t.Errorf("setIDs => %q, %q, want %q, %q", bodies, sidebars, wantBodies, wantSidebars)
+	}
+}
+
+var rendertests = []struct {
+	in, renderer, lang, out string
+}{
+	{"~~old~~", MarkdownLegacy, "sv", "<p>~~old~~</p>\n"},
+	{"~~old~~", MarkdownCommonMark, "sv", "<p><del>old</del></p>\n"},
+	{`"citat"`, MarkdownCommonMark, "sv", "<p>&rdquo;citat&rdquo;</p>\n"},
+	{`"citat"`, MarkdownCommonMark, "", "<p>&rdquo;citat&rdquo;</p>\n"},
+	{`"quote"`, MarkdownCommonMark, "en", "<p>&ldquo;quote&rdquo;</p>\n"},
+	{"Term\n: Definition", MarkdownCommonMark, "sv", "<dl>\n<dt>Term</dt>\n<dd>Definition</dd>\n</dl>\n"},
+	{"- [x] done", MarkdownCommonMark, "sv", "<ul>\n<li><input checked=\"\" disabled=\"\" type=\"checkbox\" /> done</li>\n</ul>\n"},
+	{"<b>raw</b>", MarkdownCommonMark, "sv", "<p><b>raw</b></p>\n"},
+}
+
+func TestRender(t *testing.T) {
+	for _, tt := range rendertests {
+		got, err := render([]byte(tt.in), tt.renderer, tt.lang)
+		if err != nil {
+			t.Errorf("render(%q, %q, %q) returned error %q", tt.in, tt.renderer, tt.lang, err)
+		}
+		if tt.out != got {
+			t.Errorf("render(%q, %q, %q) => %q, want %q", tt.in, tt.renderer, tt.lang, got, tt.out)
+		}
+	}
+}
+
+var writeDifftests = []struct {
+	a, b []string
+	out  string
+}{
+	{[]string{"a", "b"}, []string{"a", "b"}, ""},
+	{[]string{"a", "b", "c"}, []string{"a", "x", "c"}, " a\n-b\n+x\n c\n"},
+	{[]string{"1", "2", "3", "4", "5", "6", "7"}, []string{"0", "1", "2", "3", "4", "5", "6"}, "+0\n 1\n 2\n@@\n 5\n 6\n-7\n"},
+}
+
+func TestWriteDiff(t *testing.T) {
+	for _, tt := range writeDifftests {
+		var b strings.Builder
+		writeDiff(&b, tt.a, tt.b)
+		if got := b.String(); tt.out != got {
+			t.Errorf("writeDiff(%q, %q) => %q, want %q", tt.a, tt.b, got, tt.out)
+		}
+	}
+}
+
+var rewriteLinkstests = []struct {
+	in, out string
+}{
+	{`<a href="../stadgar">a</a>`, `<a href="/sektionen/stadgar">a</a>`},
+	{`<a href="namnder#kassor">a</a>`, `<a href="/sektionen/om-oss/namnder#kassor">a</a>`},
+	{`<a href="/nyheter">a</a>`, `<a href="/nyheter">a</a>`},
+	{`<a href="#kassor">a</a>`, `<a href="#kassor">a</a>`},
+	{`<a href="https://kth.se/x">a</a>`, `<a href="https://kth.se/x">a</a>`},
+	{`<a href="mailto:d-sys@d.kth.se">a</a>`, `<a href="mailto:d-sys@d.kth.se">a</a>`},
+	{`<img src="bild.png"/>`, `<img src="https://taitan.datasektionen.se/_assets/sektionen/om-oss/bild.png"/>`},
+	{`<img src="../../logo.svg"/>`, `<img src="https://taitan.datasektionen.se/_assets/logo.svg"/>`},
+	{`<img src="https://kth.se/bild.png"/>`, `<img src="https://kth.se/bild.png"/>`},
+	{`<a href="stadgar.pdf#page=2">a</a>`, `<a href="https://taitan.datasektionen.se/_assets/sektionen/om-oss/stadgar.pdf#page=2">a</a>`},
+	{`<a href="saknas.pdf">a</a>`, `<a href="/sektionen/om-oss/saknas.pdf">a</a>`},
+}
+
+func TestRewriteLinks(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "sektionen", "om-oss")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "stadgar.pdf"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range rewriteLinkstests {
+		got, err := rewriteLinks(tt.in, root, "/sektionen/om-oss", "https://taitan.datasektionen.se/_assets")
+		if err != nil {
+			t.Errorf("rewriteLinks(%q) returned error %q", tt.in, err)
+		}
+		if tt.out != got {
+			t.Errorf("rewriteLinks(%q) => %q, want %q", tt.in, got, tt.out)
+		}
+	}
+}
+
+func TestCheckLinks(t *testing.T) {
+	pages := map[string]*Page{
+		"/sektionen": {
+			Bodies: LangLookup{
+				"sv": `<h2 id="styrelsen">Styrelsen</h2><a href="/sektionen/">a</a><a class="x" href="/saknas">b</a>` +
+					`<a href="/stadgar#mal">c</a><a href="https://kth.se/saknas">d</a><a href="#saknas">e</a>` +
+					`<a href="/_assets/bild.png">f</a><a href="#styrelsen">g</a><a href="/nyheter#kassor">h</a>`,
+				"en": `<h2 id="the-board">The board</h2><a href="#styrelsen">a</a><a href="/nyheter#kassor">b</a>` +
+					`<a href="/Sektionen#the-board">c</a><a href="?lang=sv#styrelsen">d</a><a href="/SEKTIONEN?lang=en#styrelsen">e</a>`,
+			},
+			Sidebars: LangLookup{"sv": `<a href="/saknas">a</a>`},
+		},
+		"/nyheter": {
+			Bodies:   LangLookup{"sv": `<p>Hej</p>`},
+			Sidebars: LangLookup{"sv": `<a name="kassor"></a>`},
+		},
+	}
+	broken, err := CheckLinks(pages, func(p string) bool { return p == "/stadgar" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBroken := []BrokenLink{
+		{Page: "/sektionen", Lang: "en", Href: "#styrelsen", Missing: MissingAnchor},
+		{Page: "/sektionen", Lang: "en", Href: "/SEKTIONEN?lang=en#styrelsen", Missing: MissingAnchor},
+		{Page: "/sektionen", Lang: "sv", Href: "/saknas", Missing: MissingPage},
+		{Page: "/sektionen", Lang: "sv", Href: "#saknas", Missing: MissingAnchor},
+		{Page: "/sektionen", Lang: "sv", Sidebar: true, Href: "/saknas", Missing: MissingPage},
+	}
+	if !reflect.DeepEqual(broken, wantBroken) {
+		t.Errorf("CheckLinks => %v, want %v", broken, wantBroken)
+	}
+	want := `<h2 id="styrelsen">Styrelsen</h2><a href="/sektionen/">a</a><a class="x missing" href="/saknas">b</a>` +
+		`<a href="/stadgar#mal">c</a><a href="https://kth.se/saknas">d</a><a href="#saknas" class="missing">e</a>` +
+		`<a href="/_assets/bild.png">f</a><a href="#styrelsen">g</a><a href="/nyheter#kassor">h</a>`
+	if got := pages["/sektionen"].Bodies["sv"]; got != want {
+		t.Errorf("CheckLinks body => %q, want %q", got, want)
+	}
+	if got, want := pages["/sektionen"].Sidebars["sv"], `<a href="/saknas" class="missing">a</a>`; got != want {
+		t.Errorf("CheckLinks sidebar => %q, want %q", got, want)
+	}
+	if got, want := broken[4].String(), "/sektionen/sidebar_sv.md: broken link to /saknas, no such page"; got != want {
+		t.Errorf("BrokenLink.String() => %q, want %q", got, want)
+	}
+}
+
+var readmetatests = []struct {
+	in string
+	ok bool
+}{
+	{``, true},
+	{"[toc]\nmin_level = 2\nmax_level = 3", true},
+	{"[toc]\nmin_level = 3\nmax_level = 3", true},
+	{"[toc]\nmin_level = 4\nmax_level = 2", false},
+	{"[sidebar_toc]\nmin_level = 3", true},
+	{"[sidebar_toc]\nmax_level = 0", false},
+	{"[toc]\nmax_level = 7", false},
+	{`markdown = "html"`, false},
+}
+
+func TestReadMeta(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range readmetatests {
+		if err := os.WriteFile(filepath.Join(dir, metaFile), []byte(tt.in), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := readMeta(dir)
+		if (err == nil) != tt.ok {
+			t.Errorf("readMeta(%q) => error %v, want ok %v", tt.in, err, tt.ok)
+		}
+	}
+}
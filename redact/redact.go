@@ -0,0 +1,74 @@
+// Package redact removes credentials from strings before they are logged.
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const mask = "REDACTED"
+
+// userinfoReg matches the userinfo part of http(s) URLs, e.g. the token in
+// https://<token>@github.com/username/repo.git.
+var userinfoReg = regexp.MustCompile(`(https?://)[^/\s@]+@`)
+
+var secrets struct {
+	mu   sync.RWMutex
+	list []string
+}
+
+// Add registers a secret that should never be printed. Empty strings are
+// ignored.
+func Add(secret string) {
+	if secret == "" {
+		return
+	}
+	secrets.mu.Lock()
+	defer secrets.mu.Unlock()
+	for _, s := range secrets.list {
+		if s == secret {
+			return
+		}
+	}
+	secrets.list = append(secrets.list, secret)
+}
+
+// String returns s with all registered secrets and URL credentials masked.
+func String(s string) string {
+	s = userinfoReg.ReplaceAllString(s, "${1}"+mask+"@")
+	secrets.mu.RLock()
+	defer secrets.mu.RUnlock()
+	for _, secret := range secrets.list {
+		s = strings.ReplaceAll(s, secret, mask)
+	}
+	return s
+}
+
+// Hook is a logrus hook redacting messages and fields of every log entry.
+type Hook struct{}
+
+// Levels implements log.Hook.
+func (Hook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire implements log.Hook.
+func (Hook) Fire(entry *log.Entry) error {
+	entry.Message = String(entry.Message)
+	for k, v := range entry.Data {
+		switch v := v.(type) {
+		case string:
+			entry.Data[k] = String(v)
+		case error:
+			entry.Data[k] = errors.New(String(v.Error()))
+		case fmt.Stringer:
+			entry.Data[k] = String(v.String())
+		}
+	}
+	return nil
+}
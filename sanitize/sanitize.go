@@ -0,0 +1,279 @@
+// Package sanitize removes HTML that isn't safe to inject into a page, such
+// as scripts and event handlers, from rendered markdown.
+package sanitize
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// defaultElements are the elements allowed by Default and their attributes,
+// besides globalAttributes. They are what markdown renders to and a few more
+// that are useful in content.
+var defaultElements = map[string][]string{
+	"a": {"href", "name", "target", "rel"}, "abbr": nil, "b": nil,
+	"blockquote": {"cite"}, "br": nil, "caption": nil, "cite": nil,
+	"code": nil, "col": {"span"}, "colgroup": {"span"}, "dd": nil,
+	"del": {"cite", "datetime"}, "details": {"open"}, "dfn": nil, "div": nil,
+	"dl": nil, "dt": nil, "em": nil, "figcaption": nil, "figure": nil,
+	"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+	"hr": nil, "i": nil, "img": {"src", "alt", "width", "height"},
+	"input": {"type", "checked", "disabled"}, "ins": {"cite", "datetime"},
+	"kbd": nil, "li": {"value"}, "mark": nil, "ol": {"start", "reversed", "type"},
+	"p": nil, "pre": nil, "q": {"cite"}, "rp": nil, "rt": nil, "ruby": nil,
+	"s": nil, "samp": nil, "section": nil, "small": nil, "span": nil,
+	"strong": nil, "sub": nil, "summary": nil, "sup": nil, "table": nil,
+	"tbody": nil, "td": {"align", "colspan", "rowspan"}, "tfoot": nil,
+	"th": {"align", "colspan", "rowspan", "scope"}, "thead": nil,
+	"time": {"datetime"}, "tr": nil, "u": nil, "ul": nil, "var": nil,
+}
+
+// globalAttributes are the attributes allowed on every element by Default.
+var globalAttributes = []string{"id", "class", "title", "lang", "dir", "role"}
+
+// dropped are the elements that are removed together with their content,
+// rather than replaced by it, unless allowed.
+var dropped = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true,
+	"embed": true, "noscript": true, "template": true, "textarea": true,
+	"select": true, "svg": true, "math": true, "title": true,
+}
+
+// urlAttributes are the attributes whose values are URLs.
+var urlAttributes = map[string]bool{"href": true, "src": true, "cite": true}
+
+// safeSchemes are the schemes of the URLs allowed in urlAttributes, besides
+// relative URLs.
+var safeSchemes = map[string]bool{"http": true, "https": true, "mailto": true, "tel": true}
+
+var nameReg = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// Default is the policy of content that doesn't allow anything more.
+var Default = must(New(nil, nil))
+
+// A Policy is the HTML allowed in content.
+type Policy struct {
+	elements   map[string]map[string]bool // Allowed elements and their allowed attributes.
+	attributes map[string]bool            // Attributes allowed on every element.
+}
+
+// A Removal is an element or attribute removed by a Policy.
+type Removal struct {
+	Element   string // Name of the element, e.g. "script".
+	Attribute string // Name of the attribute, or "" if the element was removed.
+	Value     string // Value of the attribute.
+}
+
+func (r Removal) String() string {
+	if r.Attribute == "" {
+		return "<" + r.Element + ">"
+	}
+	return fmt.Sprintf("<%s %s=%q>", r.Element, r.Attribute, r.Value)
+}
+
+// New returns Default extended with elements, such as "iframe", and
+// attributes, such as "iframe.src" for an attribute of an element or
+// "style" for an attribute of all elements. Scripts and event handlers can't
+// be allowed.
+func New(elements, attributes []string) (*Policy, error) {
+	p := &Policy{
+		elements:   make(map[string]map[string]bool),
+		attributes: make(map[string]bool),
+	}
+	for e, attrs := range defaultElements {
+		p.elements[e] = make(map[string]bool)
+		for _, a := range attrs {
+			p.elements[e][a] = true
+		}
+	}
+	for _, a := range globalAttributes {
+		p.attributes[a] = true
+	}
+
+	for _, e := range elements {
+		e = strings.ToLower(e)
+		if !nameReg.MatchString(e) {
+			return nil, fmt.Errorf("%q is not an element", e)
+		}
+		if e == "script" {
+			return nil, fmt.Errorf("element %q can't be allowed", e)
+		}
+		if p.elements[e] == nil {
+			p.elements[e] = make(map[string]bool)
+		}
+	}
+	for _, a := range attributes {
+		a = strings.ToLower(a)
+		e, name, ok := strings.Cut(a, ".")
+		if !ok {
+			e, name = "", a
+		}
+		if !nameReg.MatchString(name) || (ok && !nameReg.MatchString(e)) {
+			return nil, fmt.Errorf("%q is not an attribute", a)
+		}
+		if strings.HasPrefix(name, "on") {
+			return nil, fmt.Errorf("attribute %q can't be allowed", a)
+		}
+		switch {
+		case !ok:
+			p.attributes[name] = true
+		case p.elements[e] == nil:
+			return nil, fmt.Errorf("%q is an attribute of %q, which isn't allowed", a, e)
+		default:
+			p.elements[e][name] = true
+		}
+	}
+	return p, nil
+}
+
+func must(p *Policy, err error) *Policy {
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Sanitize removes everything from the HTML body that p doesn't allow, and
+// returns the resulting HTML and what was removed. Elements that aren't
+// allowed are replaced by their content, except those in dropped, and URLs
+// that aren't http, https, mailto, tel or relative are removed. Comments
+// are removed without being reported. Links to other hosts get
+// rel="noopener", so that the pages they open can't reach the page.
+func (p *Policy) Sanitize(body string) (string, []Removal, error) {
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(body), context)
+	if err != nil {
+		return "", nil, err
+	}
+	root := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+
+	var removed []Removal
+	p.clean(root, &removed)
+
+	var b strings.Builder
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&b, c); err != nil {
+			return "", nil, err
+		}
+	}
+	return b.String(), removed, nil
+}
+
+// clean removes the children of n that p doesn't allow, appending them to
+// removed.
+func (p *Policy) clean(n *html.Node, removed *[]Removal) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch c.Type {
+		case html.CommentNode, html.DoctypeNode:
+			n.RemoveChild(c)
+		case html.ElementNode:
+			attrs, allowed := p.elements[c.Data]
+			// Of the inputs, only the checkboxes of task lists are allowed.
+			if c.Namespace != "" || c.DataAtom == atom.Input && attr(c, "type") != "checkbox" {
+				allowed = false
+			}
+			if !allowed {
+				*removed = append(*removed, Removal{Element: c.Data})
+			}
+			switch {
+			case allowed:
+				p.cleanAttrs(c, attrs, removed)
+				p.clean(c, removed)
+			case dropped[c.Data] || c.Namespace != "":
+				n.RemoveChild(c)
+			default:
+				p.clean(c, removed)
+				for gc := c.FirstChild; gc != nil; gc = c.FirstChild {
+					c.RemoveChild(gc)
+					n.InsertBefore(gc, c)
+				}
+				n.RemoveChild(c)
+			}
+		}
+		c = next
+	}
+}
+
+// cleanAttrs removes the attributes of n that aren't in attrs or allowed on
+// every element, and URLs that aren't safe, appending them to removed.
+func (p *Policy) cleanAttrs(n *html.Node, attrs map[string]bool, removed *[]Removal) {
+	kept := n.Attr[:0]
+	for _, a := range n.Attr {
+		ok := a.Namespace == "" && (attrs[a.Key] || p.attributes[a.Key])
+		if ok && urlAttributes[a.Key] {
+			ok = safeURL(a.Val)
+		}
+		if !ok {
+			*removed = append(*removed, Removal{Element: n.Data, Attribute: a.Key, Value: a.Val})
+			continue
+		}
+		kept = append(kept, a)
+	}
+	n.Attr = kept
+
+	if n.DataAtom == atom.A && external(attr(n, "href")) {
+		rel := strings.Fields(attr(n, "rel"))
+		if !contains(rel, "noopener") {
+			setAttr(n, "rel", strings.Join(append(rel, "noopener"), " "))
+		}
+	}
+}
+
+// safeURL reports whether the URL u is relative or has a safe scheme.
+func safeURL(u string) bool {
+	// Browsers ignore whitespace and control characters, as in "java\tscript:".
+	u = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, u)
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	return parsed.Scheme == "" || safeSchemes[strings.ToLower(parsed.Scheme)]
+}
+
+// external reports whether the URL u is on another host.
+func external(u string) bool {
+	parsed, err := url.Parse(strings.TrimSpace(u))
+	return err == nil && parsed.Host != ""
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func setAttr(n *html.Node, key, val string) {
+	for i, a := range n.Attr {
+		if a.Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if strings.EqualFold(l, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package sanitize
+
+import (
+	"reflect"
+	"testing"
+)
+
+var sanitizetests = []struct {
+	in, out string
+	removed []Removal
+}{
+	{`<p>Hej <em>på</em> dig</p>`, `<p>Hej <em>på</em> dig</p>`, nil},
+	{`<h2 id="kassor" class="x">Kassör</h2>`, `<h2 id="kassor" class="x">Kassör</h2>`, nil},
+	{`<p>a<script>alert(1)</script>b</p>`, `<p>ab</p>`, []Removal{{Element: "script"}}},
+	{`<style>p { color: red }</style><p>a</p>`, `<p>a</p>`, []Removal{{Element: "style"}}},
+	{`<p onclick="alert(1)">a</p>`, `<p>a</p>`, []Removal{{Element: "p", Attribute: "onclick", Value: "alert(1)"}}},
+	{`<a href="javascript:alert(1)">a</a>`, `<a>a</a>`, []Removal{{Element: "a", Attribute: "href", Value: "javascript:alert(1)"}}},
+	{"<a href=\"java\tscript:alert(1)\">a</a>", `<a>a</a>`, []Removal{{Element: "a", Attribute: "href", Value: "java\tscript:alert(1)"}}},
+	{`<a href="/sektionen#styrelsen">a</a>`, `<a href="/sektionen#styrelsen">a</a>`, nil},
+	{`<a href="mailto:d-sys@d.kth.se">a</a>`, `<a href="mailto:d-sys@d.kth.se">a</a>`, nil},
+	{`<a href="https://kth.se">a</a>`, `<a href="https://kth.se" rel="noopener">a</a>`, nil},
+	{`<a href="//kth.se" rel="nofollow">a</a>`, `<a href="//kth.se" rel="nofollow noopener">a</a>`, nil},
+	{`<font color="red">röd</font>`, `röd`, []Removal{{Element: "font"}}},
+	{`<form><p>a</p></form>`, `<p>a</p>`, []Removal{{Element: "form"}}},
+	{`<iframe src="https://youtube.com"></iframe>`, ``, []Removal{{Element: "iframe"}}},
+	{`<svg><circle r="1"/></svg>`, ``, []Removal{{Element: "svg"}}},
+	{`<p>a<!-- anteckning -->b</p>`, `<p>ab</p>`, nil},
+	{`<li><input checked="" disabled="" type="checkbox"/> klart</li>`, `<li><input checked="" disabled="" type="checkbox"/> klart</li>`, nil},
+	{`<input type="text"/>`, ``, []Removal{{Element: "input"}}},
+	{`<img src="data:image/png;base64,AA" onerror="alert(1)"/>`, `<img/>`, []Removal{
+		{Element: "img", Attribute: "src", Value: "data:image/png;base64,AA"},
+		{Element: "img", Attribute: "onerror", Value: "alert(1)"},
+	}},
+}
+
+func TestSanitize(t *testing.T) {
+	for _, tt := range sanitizetests {
+		got, removed, err := Default.Sanitize(tt.in)
+		if err != nil {
+			t.Errorf("Sanitize(%q) => error %v", tt.in, err)
+			continue
+		}
+		if got != tt.out || !reflect.DeepEqual(removed, tt.removed) {
+			t.Errorf("Sanitize(%q) => %q, %v, want %q, %v", tt.in, got, removed, tt.out, tt.removed)
+		}
+	}
+}
+
+var newtests = []struct {
+	elements, attributes []string
+	in, out              string
+	ok                   bool
+}{
+	{[]string{"iframe"}, []string{"iframe.src"}, `<iframe src="https://youtube.com"></iframe>`, `<iframe src="https://youtube.com"></iframe>`, true},
+	{[]string{"iframe"}, nil, `<iframe src="https://youtube.com"></iframe>`, `<iframe></iframe>`, true},
+	{nil, []string{"style"}, `<p style="color: red">a</p>`, `<p style="color: red">a</p>`, true},
+	{nil, []string{"p.data-x"}, `<p data-x="1" data-y="2">a</p>`, `<p data-x="1">a</p>`, true},
+	{[]string{"script"}, nil, "", "", false},
+	{nil, []string{"onclick"}, "", "", false},
+	{nil, []string{"a.onclick"}, "", "", false},
+	{nil, []string{"iframe.src"}, "", "", false},
+	{[]string{"<p>"}, nil, "", "", false},
+}
+
+func TestNew(t *testing.T) {
+	for _, tt := range newtests {
+		p, err := New(tt.elements, tt.attributes)
+		if (err == nil) != tt.ok {
+			t.Errorf("New(%q, %q) => error %v, want ok %v", tt.elements, tt.attributes, err, tt.ok)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		got, _, err := p.Sanitize(tt.in)
+		if err != nil || got != tt.out {
+			t.Errorf("New(%q, %q).Sanitize(%q) => %q, %v, want %q", tt.elements, tt.attributes, tt.in, got, err, tt.out)
+		}
+	}
+}
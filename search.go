@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/datasektionen/taitan/search"
+	log "github.com/sirupsen/logrus"
+)
+
+// Number of results of a search, unless the limit parameter says otherwise,
+// and the most it may say.
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// searchResp is the response of /search.
+type searchResp struct {
+	Query   string          `json:"query"`
+	Lang    string          `json:"lang"`
+	Results []search.Result `json:"results"`
+}
+
+// searchHandler answers the query in the q parameter with the pages of m in
+// the language lang that match it best.
+func searchHandler(res http.ResponseWriter, req *http.Request, m *mount, lang string) {
+	q := req.URL.Query().Get("q")
+	limit, err := strconv.Atoi(req.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+
+	responses := &m.responses
+	responses.Lock()
+	tag := etag(responses.Revision, "/search", lang, q, strconv.Itoa(limit))
+	index := responses.Index
+	responses.Unlock()
+	if m.notModified(res, req, tag, time.Time{}) {
+		return
+	}
+
+	resp := searchResp{Query: q, Lang: lang, Results: index.Search(lang, q, limit)}
+	if resp.Results == nil {
+		resp.Results = []search.Result{}
+	}
+	buf, err := json.Marshal(resp)
+	if err != nil {
+		log.Warnf("searchHandler: unexpected error: %#v\n", err)
+		writeError(res, http.StatusInternalServerError, apiError{
+			Code:    codeInternal,
+			Message: "Could not create the search results",
+			Path:    "/search",
+			Lang:    lang,
+		})
+		return
+	}
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	res.Write(buf)
+}
@@ -0,0 +1,422 @@
+// Package search is a full-text index of the pages of the content.
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/datasektionen/taitan/anchor"
+	"github.com/datasektionen/taitan/fold"
+	"github.com/datasektionen/taitan/pages"
+
+	"golang.org/x/net/html"
+)
+
+// Weights of the occurrences of a word in the parts of a page.
+const (
+	weightTitle   = 5
+	weightHeading = 3
+	weightBody    = 1
+	weightSidebar = 0.5
+)
+
+const (
+	// weightPrefix is the weight of words that the last word of a query is a
+	// prefix of, relative to the word itself.
+	weightPrefix = 0.5
+	// maxPrefixed is the largest number of words a prefix is expanded to.
+	maxPrefixed = 50
+	// snippetLength is the approximate length of snippets, in bytes.
+	snippetLength = 160
+)
+
+// Result is a page matching a query.
+type Result struct {
+	Path    string         `json:"path"`
+	Title   string         `json:"title"`
+	Anchor  *anchor.Anchor `json:"anchor,omitempty"` // The heading of the part of the page that matches best.
+	Snippet string         `json:"snippet"`          // HTML excerpt of the match, with the matching words in <mark>.
+	Score   float64        `json:"score"`
+}
+
+// Index is an inverted index of the pages of a content snapshot, one per
+// language.
+type Index struct {
+	langs map[string]*langIndex
+}
+
+type langIndex struct {
+	stem  func(string) string
+	docs  []doc
+	terms map[string][]posting // Documents by the terms in them.
+	vocab []string             // The keys of terms, sorted.
+}
+
+// doc is a page in one language.
+type doc struct {
+	path     string
+	title    string
+	sections []section
+}
+
+// section is the text under a heading of a page, or its sidebar.
+type section struct {
+	anchor *anchor.Anchor // nil before the first heading and for the sidebar.
+	text   string
+}
+
+type posting struct {
+	doc    int
+	weight float64 // The sum of the weights of the occurrences of the term.
+}
+
+// token is a word in a text.
+type token struct {
+	start, end int // Byte offsets of the word.
+	term       string
+}
+
+// New indexes the titles, headings, bodies and sidebars of resps.
+func New(resps map[string]*pages.Page) *Index {
+	paths := make([]string, 0, len(resps))
+	for p := range resps {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	idx := &Index{langs: make(map[string]*langIndex)}
+	for _, p := range paths {
+		page := resps[p]
+		for lang := range page.Bodies {
+			li, ok := idx.langs[lang]
+			if !ok {
+				li = &langIndex{stem: stemmers[lang], terms: make(map[string][]posting)}
+				if li.stem == nil {
+					li.stem = func(w string) string { return w }
+				}
+				idx.langs[lang] = li
+			}
+			li.add(p, page, lang)
+		}
+	}
+	for _, li := range idx.langs {
+		for t := range li.terms {
+			li.vocab = append(li.vocab, t)
+		}
+		sort.Strings(li.vocab)
+	}
+	return idx
+}
+
+// add indexes page at p in lang.
+func (li *langIndex) add(p string, page *pages.Page, lang string) {
+	d := doc{path: p, title: page.Titles[lang], sections: sections(page.Bodies[lang], page.Anchors[lang])}
+	weights := make(map[string]float64)
+	li.count(weights, d.title, weightTitle)
+	for _, s := range d.sections {
+		if s.anchor != nil {
+			li.count(weights, s.anchor.Value, weightHeading)
+		}
+		li.count(weights, s.text, weightBody)
+	}
+	if sidebar := text(page.Sidebars[lang]); sidebar != "" {
+		d.sections = append(d.sections, section{text: sidebar})
+		li.count(weights, sidebar, weightSidebar)
+	}
+
+	n := len(li.docs)
+	li.docs = append(li.docs, d)
+	for t, w := range weights {
+		li.terms[t] = append(li.terms[t], posting{doc: n, weight: w})
+	}
+}
+
+// count adds weight to the terms of s.
+func (li *langIndex) count(weights map[string]float64, s string, weight float64) {
+	for _, t := range li.tokens(s) {
+		weights[t.term] += weight
+	}
+}
+
+// tokens splits s into words and returns them with their terms: folded to
+// lower case without diacritics, and stemmed.
+func (li *langIndex) tokens(s string) []token {
+	var ts []token
+	start := -1
+	for i, c := range s + " " {
+		word := unicode.IsLetter(c) || unicode.IsDigit(c)
+		if word && start < 0 {
+			start = i
+		} else if !word && start >= 0 {
+			ts = append(ts, token{start: start, end: i, term: li.stem(fold.String(s[start:i]))})
+			start = -1
+		}
+	}
+	return ts
+}
+
+// prefixed returns the terms starting with prefix.
+func (li *langIndex) prefixed(prefix string) []string {
+	var ts []string
+	for i := sort.SearchStrings(li.vocab, prefix); i < len(li.vocab) && len(ts) < maxPrefixed; i++ {
+		if !strings.HasPrefix(li.vocab[i], prefix) {
+			break
+		}
+		ts = append(ts, li.vocab[i])
+	}
+	return ts
+}
+
+// Search returns at most limit pages in lang matching the words of q, best
+// first. Pages matching more of the words rank higher, and the last word
+// also matches words it is the beginning of, so that results can be shown
+// while typing.
+func (idx *Index) Search(lang, q string, limit int) []Result {
+	li, ok := idx.langs[lang]
+	if !ok {
+		return nil
+	}
+	var qterms []string
+	for _, t := range li.tokens(q) {
+		if !contains(qterms, t.term) {
+			qterms = append(qterms, t.term)
+		}
+	}
+
+	scores := make(map[int]float64)
+	matched := make(map[int]int)  // Number of words of q matched by each document.
+	hits := make(map[string]bool) // Terms to highlight.
+	for i, qt := range qterms {
+		terms := []string{qt}
+		if i == len(qterms)-1 {
+			terms = li.prefixed(qt)
+		}
+		found := make(map[int]bool)
+		for _, t := range terms {
+			ps := li.terms[t]
+			if len(ps) == 0 {
+				continue
+			}
+			hits[t] = true
+			weight := math.Log(1 + float64(len(li.docs))/float64(len(ps)))
+			if t != qt {
+				weight *= weightPrefix
+			}
+			for _, p := range ps {
+				scores[p.doc] += weight * math.Log1p(p.weight)
+				found[p.doc] = true
+			}
+		}
+		for d := range found {
+			matched[d]++
+		}
+	}
+
+	ds := make([]int, 0, len(scores))
+	for d := range scores {
+		scores[d] *= float64(matched[d]) / float64(len(qterms))
+		ds = append(ds, d)
+	}
+	sort.Slice(ds, func(i, j int) bool {
+		if scores[ds[i]] != scores[ds[j]] {
+			return scores[ds[i]] > scores[ds[j]]
+		}
+		return li.docs[ds[i]].path < li.docs[ds[j]].path
+	})
+	if len(ds) > limit {
+		ds = ds[:limit]
+	}
+
+	rs := make([]Result, len(ds))
+	for i, d := range ds {
+		rs[i] = Result{Path: li.docs[d].path, Title: li.docs[d].title, Score: scores[d]}
+		rs[i].Anchor, rs[i].Snippet = li.snippet(li.docs[d], hits)
+	}
+	return rs
+}
+
+// snippet returns the heading of the section of d with the most hits, in
+// the heading or the text, and an excerpt of its text around the first hit.
+func (li *langIndex) snippet(d doc, hits map[string]bool) (*anchor.Anchor, string) {
+	var best *section
+	var bestTokens []token
+	most := -1
+	for i := range d.sections {
+		s := &d.sections[i]
+		ts := li.tokens(s.text)
+		n := countHits(ts, hits)
+		if s.anchor != nil {
+			n += countHits(li.tokens(s.anchor.Value), hits)
+		}
+		if n > most {
+			best, bestTokens, most = s, ts, n
+		}
+	}
+	if best == nil {
+		return nil, ""
+	}
+
+	// A heading without text is its own excerpt.
+	body := best.text
+	if body == "" && best.anchor != nil {
+		body = best.anchor.Value
+		bestTokens = li.tokens(body)
+	}
+	first := token{}
+	for _, t := range bestTokens {
+		if hits[t.term] {
+			first = t
+			break
+		}
+	}
+	start, end := 0, len(body)
+	if first.start > snippetLength/3 {
+		start = first.start - snippetLength/3
+		if i := strings.IndexByte(body[start:first.start], ' '); i >= 0 {
+			start += i + 1
+		}
+		for start < len(body) && !utf8.RuneStart(body[start]) {
+			start++
+		}
+	}
+	if end-start > snippetLength {
+		end = max(start+snippetLength, first.end)
+		if i := strings.LastIndexByte(body[first.end:end], ' '); i >= 0 {
+			end = first.end + i
+		}
+		for end < len(body) && !utf8.RuneStart(body[end]) {
+			end++
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("… ")
+	}
+	pos := start
+	for _, t := range bestTokens {
+		if t.start < start || t.end > end || !hits[t.term] {
+			continue
+		}
+		b.WriteString(html.EscapeString(body[pos:t.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(body[t.start:t.end]))
+		b.WriteString("</mark>")
+		pos = t.end
+	}
+	b.WriteString(html.EscapeString(body[pos:end]))
+	if end < len(body) {
+		b.WriteString(" …")
+	}
+	return best.anchor, b.String()
+}
+
+// countHits returns the number of ts that are hits.
+func countHits(ts []token, hits map[string]bool) int {
+	n := 0
+	for _, t := range ts {
+		if hits[t.term] {
+			n++
+		}
+	}
+	return n
+}
+
+// sections splits the HTML body into the text under each of its anchors.
+func sections(body string, anchors []anchor.Anchor) []section {
+	root, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return nil
+	}
+	byID := make(map[string]*anchor.Anchor, len(anchors))
+	for i := range anchors {
+		byID[anchors[i].ID] = &anchors[i]
+	}
+	ss := []section{{}}
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		a := byID[id(n)]
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+		case skipped(n):
+		case n.Type == html.ElementNode && a != nil:
+			ss[len(ss)-1].text = normalize(b.String())
+			b.Reset()
+			ss = append(ss, section{anchor: a})
+		default:
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				walk(c)
+			}
+		}
+	}
+	walk(root)
+	ss[len(ss)-1].text = normalize(b.String())
+
+	if ss[0].text == "" {
+		ss = ss[1:]
+	}
+	return ss
+}
+
+// text returns the text of the HTML s with whitespace normalized.
+func text(s string) string {
+	n, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		return ""
+	}
+	return nodeText(n)
+}
+
+// nodeText returns the text of n with whitespace normalized.
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	collect(&b, n)
+	return normalize(b.String())
+}
+
+// collect writes the text of n to b, leaving out scripts and styles.
+func collect(b *strings.Builder, n *html.Node) {
+	switch {
+	case n.Type == html.TextNode:
+		b.WriteString(n.Data)
+	case skipped(n):
+	default:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(b, c)
+		}
+	}
+}
+
+// skipped reports whether n is an element without text, i.e. a script or a
+// style.
+func skipped(n *html.Node) bool {
+	return n.Type == html.ElementNode && (n.Data == "script" || n.Data == "style")
+}
+
+func normalize(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// id returns the id attribute of n.
+func id(n *html.Node) string {
+	for _, a := range n.Attr {
+		if a.Key == "id" {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func contains(ss []string, s string) bool {
+	for _, t := range ss {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/datasektionen/taitan/pages"
+)
+
+var stemtests = []struct {
+	in, out string
+}{
+	{"namnder", "namnd"},
+	{"namnden", "namnd"},
+	{"namnd", "namnd"},
+	{"kassorna", "kass"},
+	{"kassor", "kass"},
+	{"styrelsen", "styr"},
+	{"styrelse", "styr"},
+	{"sektionens", "sektion"},
+	{"sektionen", "sektion"},
+	{"hus", "hus"},
+}
+
+func TestStemSwedish(t *testing.T) {
+	for _, tt := range stemtests {
+		got := stemSwedish(tt.in)
+		if got != tt.out {
+			t.Errorf("stemSwedish(%q) => %q, want %q", tt.in, got, tt.out)
+		}
+	}
+}
+
+var testPages = map[string]*pages.Page{
+	"/": {
+		Titles: pages.LangLookup{"sv": "Konglig Datasektionen"},
+		Bodies: pages.LangLookup{"sv": "<p>Välkommen till sektionen.</p>"},
+	},
+	"/sektionen/namnder": {
+		Titles: pages.LangLookup{"sv": "Nämnder", "en": "Committees"},
+		Bodies: pages.LangLookup{
+			"sv": `<p>Sektionen har många nämnder.</p>
+<h2 id="kassor">Kassörer &amp; ekonomi</h2>
+<p>Varje nämnd har en kassör som sköter nämndens pengar.</p>`,
+			"en": "<p>The chapter has many committees.</p>",
+		},
+		Anchors: pages.LangAnchorLookup{
+			"sv": {{ID: "kassor", Value: "Kassörer & ekonomi", HeaderLevel: 2, HTML: "Kassörer &amp; ekonomi"}},
+			"en": {},
+		},
+		Sidebars: pages.LangLookup{"sv": "<p>Kontakta styrelsen</p>"},
+	},
+	"/sektionen/styrelsen": {
+		Titles: pages.LangLookup{"sv": "Styrelsen"},
+		Bodies: pages.LangLookup{"sv": "<p>Styrelsen leder sektionen.</p>"},
+	},
+}
+
+var searchtests = []struct {
+	lang, q string
+	paths   []string
+	anchor  string
+	snippet string
+}{
+	{"sv", "nämnder", []string{"/sektionen/namnder"}, "kassor", "Varje <mark>nämnd</mark> har en kassör som sköter <mark>nämndens</mark> pengar."},
+	{"sv", "MÅNGA namnd", []string{"/sektionen/namnder"}, "", "Sektionen har <mark>många</mark> <mark>nämnder</mark>."},
+	{"sv", "kassör", []string{"/sektionen/namnder"}, "kassor", "Varje nämnd har en <mark>kassör</mark> som sköter nämndens pengar."},
+	{"sv", "styrelsen", []string{"/sektionen/styrelsen", "/sektionen/namnder"}, "", "<mark>Styrelsen</mark> leder sektionen."},
+	{"sv", "styr", []string{"/sektionen/styrelsen", "/sektionen/namnder"}, "", "<mark>Styrelsen</mark> leder sektionen."},
+	{"en", "committee", []string{"/sektionen/namnder"}, "", "The chapter has many <mark>committees</mark>."},
+	{"en", "committees", []string{"/sektionen/namnder"}, "", "The chapter has many <mark>committees</mark>."},
+	{"sv", "ingenting", nil, "", ""},
+	{"de", "nämnder", nil, "", ""},
+}
+
+func TestSearch(t *testing.T) {
+	idx := New(testPages)
+	for _, tt := range searchtests {
+		rs := idx.Search(tt.lang, tt.q, 10)
+		var paths []string
+		for _, r := range rs {
+			paths = append(paths, r.Path)
+		}
+		if len(paths) != len(tt.paths) {
+			t.Errorf("Search(%q, %q) => %q, want %q", tt.lang, tt.q, paths, tt.paths)
+			continue
+		}
+		for i := range paths {
+			if paths[i] != tt.paths[i] {
+				t.Errorf("Search(%q, %q) => %q, want %q", tt.lang, tt.q, paths, tt.paths)
+				break
+			}
+		}
+		if len(rs) == 0 {
+			continue
+		}
+		anchor := ""
+		if rs[0].Anchor != nil {
+			anchor = rs[0].Anchor.ID
+			if rs[0].Anchor.HTML == "" {
+				t.Errorf("Search(%q, %q)[0].Anchor has no HTML", tt.lang, tt.q)
+			}
+		}
+		if anchor != tt.anchor {
+			t.Errorf("Search(%q, %q)[0].Anchor => %q, want %q", tt.lang, tt.q, anchor, tt.anchor)
+		}
+		if rs[0].Snippet != tt.snippet {
+			t.Errorf("Search(%q, %q)[0].Snippet => %q, want %q", tt.lang, tt.q, rs[0].Snippet, tt.snippet)
+		}
+	}
+}
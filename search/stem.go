@@ -0,0 +1,84 @@
+package search
+
+import "strings"
+
+// stemmers are the stemmers by language. Languages without a stemmer are
+// only folded. Pages without a language are in Swedish.
+var stemmers = map[string]func(string) string{
+	"":   stemSwedish,
+	"sv": stemSwedish,
+}
+
+// Suffixes removed by stemSwedish, longest first.
+var (
+	swedishSuffixes = []string{
+		"heterna", "hetens", "anden", "andes", "andet", "arens", "arnas",
+		"ernas", "heten", "heter", "ornas", "ande", "ades", "aren", "arna",
+		"arne", "aste", "erna", "erns", "orna", "ade", "are", "ast", "ens",
+		"ern", "het", "ad", "ar", "as", "at", "en", "er", "es", "or", "a",
+		"e",
+	}
+	swedishDoubles = []string{"dd", "gd", "nn", "dt", "gt", "kt", "tt"}
+)
+
+// stemSwedish returns the stem of the folded Swedish word w. It is a light
+// version of the Snowball stemmer for Swedish, working on words where å, ä
+// and ö have been folded to a and o.
+func stemSwedish(w string) string {
+	r1 := region1(w)
+	if r1 >= len(w) {
+		return w
+	}
+
+	w = trimInflection(w, r1)
+	for _, s := range swedishDoubles {
+		if strings.HasSuffix(w, s) && len(w)-len(s) >= r1 {
+			w = w[:len(w)-1]
+			break
+		}
+	}
+
+	// Derivational suffixes.
+	switch {
+	case strings.HasSuffix(w, "fullt") && len(w)-5 >= r1:
+		w = w[:len(w)-1]
+	case strings.HasSuffix(w, "lost") && len(w)-4 >= r1:
+		w = w[:len(w)-1]
+	case strings.HasSuffix(w, "lig") && len(w)-3 >= r1:
+		w = w[:len(w)-3]
+	case strings.HasSuffix(w, "els") && len(w)-3 >= r1:
+		w = w[:len(w)-3]
+	case strings.HasSuffix(w, "ig") && len(w)-2 >= r1:
+		w = w[:len(w)-2]
+	}
+	return w
+}
+
+// trimInflection removes the longest inflectional suffix of w after r1.
+func trimInflection(w string, r1 int) string {
+	for _, s := range swedishSuffixes {
+		if strings.HasSuffix(w, s) && len(w)-len(s) >= r1 {
+			return w[:len(w)-len(s)]
+		}
+	}
+	if len(w) > 1 && len(w)-1 >= r1 && w[len(w)-1] == 's' &&
+		strings.IndexByte("bcdfghjklmnoprtvy", w[len(w)-2]) >= 0 {
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+// region1 returns where the region of w that suffixes may be removed from
+// starts: after the first consonant following a vowel, but at least at 3.
+func region1(w string) int {
+	for i := 1; i < len(w); i++ {
+		if isVowel(w[i-1]) && !isVowel(w[i]) {
+			return max(i+1, 3)
+		}
+	}
+	return len(w)
+}
+
+func isVowel(c byte) bool {
+	return strings.IndexByte("aeiouy", c) >= 0
+}
@@ -0,0 +1,139 @@
+// Package suggest finds existing paths close to a path that doesn't exist.
+package suggest
+
+import (
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/datasektionen/taitan/fold"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Scores of the kinds of matches, lower is better. The edit distance between
+// the slugs is added to the score.
+const (
+	scoreSibling   = 0 // Same directory.
+	scoreElsewhere = 4 // Another directory, after all in the same.
+)
+
+// Candidate is an existing path close to the requested one.
+type Candidate struct {
+	Path  string
+	Score int // How far Path is from the requested path, lower is closer.
+}
+
+// Suggest returns at most max of paths close to p, closest first. Paths are
+// close if they are equal to p except for case and diacritics, if they are
+// in the same directory and their slug is within a small edit distance, or
+// if their slug matches the slug of p but they are in another directory.
+func Suggest(paths []string, p string, max int) []Candidate {
+	fp := fold.String(p)
+	dir, slug := path.Split(fp)
+	limit := maxDistance(slug)
+
+	var cs []Candidate
+	for _, q := range paths {
+		if q == p || q == "/" {
+			continue
+		}
+		fq := fold.String(q)
+		qdir, qslug := path.Split(fq)
+		d := Distance(slug, qslug)
+		if qdir == dir && d <= limit {
+			cs = append(cs, Candidate{q, scoreSibling + d})
+		} else if qdir != dir && d <= limit/2 {
+			cs = append(cs, Candidate{q, scoreElsewhere + d})
+		}
+	}
+	sort.Slice(cs, func(i, j int) bool {
+		if cs[i].Score != cs[j].Score {
+			return cs[i].Score < cs[j].Score
+		}
+		return cs[i].Path < cs[j].Path
+	})
+	if len(cs) > max {
+		cs = cs[:max]
+	}
+	return cs
+}
+
+// Unambiguous returns the path of the first of cs if it is so close that it
+// is safe to redirect to it, and no other candidate is as close. That is the
+// case if it differs only in case and diacritics, or if it has the same slug
+// in another directory. A slug a single edit away may well be another page,
+// e.g. master-c and master-a or 2024 and 2023, so it is only suggested.
+func Unambiguous(cs []Candidate) (string, bool) {
+	if len(cs) == 0 {
+		return "", false
+	}
+	if s := cs[0].Score; s != scoreSibling && s != scoreElsewhere {
+		return "", false
+	}
+	if len(cs) > 1 && cs[1].Score == cs[0].Score {
+		return "", false
+	}
+	return cs[0].Path, true
+}
+
+// maxDistance returns the largest edit distance at which slug is considered
+// misspelled rather than a different word.
+func maxDistance(slug string) int {
+	n := len([]rune(slug))
+	switch {
+	case n < 4:
+		return 1
+	case n < 8:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// Canonical returns the canonical form of the path p: percent-escapes left
+// in it are decoded, bytes that aren't UTF-8 are read as Latin-1, and it is
+// normalized to NFC and case folded. Paths that differ only in these ways
+// have the same canonical form.
+func Canonical(p string) string {
+	for strings.Contains(p, "%") {
+		u, err := url.PathUnescape(p)
+		if err != nil || u == p {
+			break
+		}
+		p = u
+	}
+	if !utf8.ValidString(p) {
+		if u, err := charmap.ISO8859_1.NewDecoder().String(p); err == nil {
+			p = u
+		}
+	}
+	return cases.Fold().String(norm.NFC.String(p))
+}
+
+// Distance returns the Levenshtein distance between a and b, counted in
+// runes.
+func Distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
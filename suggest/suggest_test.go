@@ -0,0 +1,100 @@
+package suggest
+
+import (
+	"reflect"
+	"testing"
+)
+
+var canonicaltests = []struct {
+	in, out string
+}{
+	{"/om-oss", "/om-oss"},
+	{"/Om-Oss", "/om-oss"},
+	{"/sektionen/N\u00c4MNDER", "/sektionen/n\u00e4mnder"},
+	{"/sektionen/na\u0308mnder", "/sektionen/n\u00e4mnder"},
+	{"/sektionen/n%C3%A4mnder", "/sektionen/n\u00e4mnder"},
+	{"/sektionen/n%25C3%25A4mnder", "/sektionen/n\u00e4mnder"},
+	{"/sektionen/n\xe4mnder", "/sektionen/n\u00e4mnder"},
+	{"/100%", "/100%"},
+}
+
+func TestCanonical(t *testing.T) {
+	for _, tt := range canonicaltests {
+		got := Canonical(tt.in)
+		if got != tt.out {
+			t.Errorf("Canonical(%q) => %q, want %q", tt.in, got, tt.out)
+		}
+	}
+}
+
+var distancetests = []struct {
+	a, b string
+	out  int
+}{
+	{"", "", 0},
+	{"kassor", "kassor", 0},
+	{"kasor", "kassor", 1},
+	{"nämnder", "namnder", 1},
+	{"styrelse", "styrelsen", 1},
+	{"kitten", "sitting", 3},
+	{"", "abc", 3},
+}
+
+func TestDistance(t *testing.T) {
+	for _, tt := range distancetests {
+		got := Distance(tt.a, tt.b)
+		if got != tt.out {
+			t.Errorf("Distance(%q, %q) => %d, want %d", tt.a, tt.b, got, tt.out)
+		}
+	}
+}
+
+var paths = []string{
+	"/",
+	"/sektionen",
+	"/sektionen/nämnder",
+	"/sektionen/styrelsen",
+	"/sektionen/organisation/kassor",
+	"/utbildning",
+	"/utbildning/kurser",
+	"/utbildning/kursval",
+	"/utbildning/master-a",
+	"/utbildning/master-b",
+	"/programmet/master-a",
+	"/protokoll/2023",
+	"/om",
+}
+
+var suggesttests = []struct {
+	in  string
+	out []string
+	ok  bool // Whether the first suggestion is unambiguous.
+}{
+	{"/sektionen/namnder", []string{"/sektionen/nämnder"}, true},
+	{"/Sektionen/Styrelsen", []string{"/sektionen/styrelsen"}, true},
+	{"/sektionen/styrelse", []string{"/sektionen/styrelsen"}, false},
+	{"/sektionen/kassor", []string{"/sektionen/organisation/kassor"}, true},
+	{"/utbildning/kursr", []string{"/utbildning/kurser"}, false},
+	{"/utbildning/kursvl", []string{"/utbildning/kursval", "/utbildning/kurser"}, false},
+	{"/utbildning/master-c", []string{"/utbildning/master-a", "/utbildning/master-b", "/programmet/master-a"}, false},
+	{"/programmet/master-c", []string{"/programmet/master-a", "/utbildning/master-a", "/utbildning/master-b"}, false},
+	{"/protokoll/2024", []string{"/protokoll/2023"}, false},
+	{"/on", []string{"/om"}, false},
+	{"/nothing/like/this", nil, false},
+}
+
+func TestSuggest(t *testing.T) {
+	for _, tt := range suggesttests {
+		cs := Suggest(paths, tt.in, 5)
+		var got []string
+		for _, c := range cs {
+			got = append(got, c.Path)
+		}
+		if !reflect.DeepEqual(got, tt.out) {
+			t.Errorf("Suggest(%q) => %q, want %q", tt.in, got, tt.out)
+		}
+		if _, ok := Unambiguous(cs); ok != tt.ok {
+			t.Errorf("Unambiguous(Suggest(%q)) => %v, want %v", tt.in, ok, tt.ok)
+		}
+	}
+}
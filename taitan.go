@@ -1,34 +1,41 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
-	"net/url"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/datasektionen/taitan/anchor"
 	"github.com/datasektionen/taitan/fuzz"
 	"github.com/datasektionen/taitan/pages"
+	"github.com/datasektionen/taitan/redact"
+	"github.com/datasektionen/taitan/search"
+	"github.com/datasektionen/taitan/suggest"
 	"github.com/rjeczalik/notify"
 	log "github.com/sirupsen/logrus"
 )
 
 var (
-	debug     bool   // Show debug level messages.
-	info      bool   // Show info level messages.
-	watch     bool   // Watch for file changes.
-	responses Atomic // Our parsed responses.
+	debug      bool   // Show debug level messages.
+	info       bool   // Show info level messages.
+	watch      bool   // Watch for file changes.
+	configFile string // Path to the configuration file.
 )
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS] [config check | markdown diff DIR... | lint DIR...]\n", os.Args[0])
 	flag.PrintDefaults()
 	os.Exit(1)
 }
@@ -37,94 +44,17 @@ func init() {
 	flag.BoolVar(&debug, "vv", false, "Print debug messages.")
 	flag.BoolVar(&info, "v", false, "Print info messages.")
 	flag.BoolVar(&watch, "w", false, "Watch for file changes.")
+	flag.StringVar(&configFile, "c", os.Getenv("CONFIG_FILE"), "Path to a TOML configuration file.")
 	flag.Usage = usage
-	flag.Parse()
-}
-
-func getEnv(env string) string {
-	e, found := os.LookupEnv(env)
-	if !found {
-		log.Fatalf("$%s environmental variable is not set.\n", env)
-	}
-	return e
-}
-
-func getRoot() string {
-	if contentDir, ok := os.LookupEnv("CONTENT_DIR"); ok {
-		return contentDir
-	}
-	content := getEnv("CONTENT_URL")
-	u, err := url.Parse(content)
-	if err != nil {
-		log.Fatalln("getContent: ", err)
-	}
-
-	base := filepath.Base(u.Path)
-	return strings.TrimSuffix(base, filepath.Ext(base))
-}
-
-func getContent() error {
-	if _, ok := os.LookupEnv("CONTENT_DIR"); ok {
-		return nil
-	}
-	content := getEnv("CONTENT_URL")
-	u, err := url.Parse(content)
-	if err != nil {
-		log.Fatalln("getContent: ", err)
-	}
-
-	// https://<token>@github.com/username/repo.git
-	githubToken, tokenFound := os.LookupEnv("TOKEN")
-	if tokenFound {
-		u.User = url.User(githubToken)
-	}
-
-	root := getRoot()
-	if _, err = os.Stat(root); os.IsNotExist(err) {
-		if err := runGit("clone", "clone", u.String()); err != nil {
-			return err
-		}
-		if err := runGit("submodule init", "-C", root, "submodule", "init"); err != nil {
-			return err
-		}
-		if err := runGit("submodule update", "-C", root, "submodule", "update"); err != nil {
-			return err
-		}
-	} else {
-		if err := runGit("pull", "-C", root, "pull"); err != nil {
-			return err
-		}
-		if err := runGit("submodule update", "-C", root, "submodule", "update"); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func runGit(action string, args ...string) error {
-	log.Infof("Found root directory - %sing updates!", action)
-	log.Debugf("Commands %#v!", args)
-	cmd := exec.Command("git", args...)
-	err := cmd.Start()
-	if err != nil {
-		return fmt.Errorf("Could not start %sing: %w\n", action, err)
-	}
-	log.Infof("Waiting for git %s to finish...", action)
-	err = cmd.Wait()
-	if err != nil {
-		return fmt.Errorf("Could not %s: %w\n", action, err)
-	}
-
-	log.Infof("Git %s finished!", action)
-	return nil
 }
 
-// setVerbosity sets the amount of messages printed.
-func setVerbosity() {
+// setVerbosity sets the amount of messages printed. The flags take precedence
+// over the configured level.
+func setVerbosity(level string) {
 	switch {
-	case debug:
+	case debug || level == "debug":
 		log.SetLevel(log.DebugLevel)
-	case info:
+	case info || level == "info":
 		log.SetLevel(log.InfoLevel)
 	default:
 		log.SetLevel(log.WarnLevel)
@@ -134,7 +64,17 @@ func setVerbosity() {
 // Atomic responses.
 type Atomic struct {
 	sync.Mutex
-	Resps map[string]*pages.Page
+	Resps     map[string]*pages.Page
+	Canonical map[string]string // Paths of Resps by their canonical form, see pages.CanonicalPaths.
+	Index     *search.Index     // Full-text index of Resps.
+	Jumpfile  map[string]interface{}
+	Commit    string        // The served commit of the content, if known.
+	Revision  string        // Identifies the content of the responses, see revisionOf.
+	Cache     *encodedCache // Serialized responses of this revision.
+	LoadedAt  time.Time     // When the responses were loaded.
+	Modified  time.Time     // Newest update time of Resps, see lastModified.
+
+	Broken []pages.BrokenLink // Links in Resps to pages or ids that don't exist.
 }
 
 func validRoot(root string) {
@@ -150,74 +90,172 @@ func validRoot(root string) {
 	}
 }
 
-var jumpfile map[string]interface{}
-
 func main() {
-	setVerbosity()
-
-	// Get port or die.
-	port := getEnv("PORT")
-
-	if err := getContent(); err != nil {
-		panic(err)
+	flag.Parse()
+	log.AddHook(redact.Hook{})
+
+	cfg, err := loadConfig(configFile)
+	if args := flag.Args(); len(args) > 0 {
+		switch {
+		case len(args) == 2 && args[0] == "config" && args[1] == "check":
+			configCheck(cfg, err)
+		case len(args) >= 2 && args[0] == "markdown" && args[1] == "diff":
+			markdownDiff(args[2:])
+		case args[0] == "lint":
+			lint(args[1:], cfg, err)
+		default:
+			usage()
+		}
 	}
-
-	root := getRoot()
-	log.WithField("Root", root).Info("Our root directory")
-
-	isReception, err := getDarkmode()
 	if err != nil {
-		panic(err)
+		log.Fatalln(err)
 	}
+	setVerbosity(cfg.LogLevel)
+	watch = watch || cfg.Watch
+	readyTolerance = cfg.ReadyTolerance.Duration
+	setupAccessLog(cfg.AccessLog, cfg.AccessLogSample)
 
-	// We'll parse and store the responses ahead of time.
-	resps, err := pages.Load(isReception, root)
+	mounts, err = loadMounts(cfg)
 	if err != nil {
-		log.Fatalf("pages.Load: unexpected error: %s", err)
+		log.Fatalln(err)
 	}
-	log.WithField("Resps", resps).Debug("The parsed responses")
-	responses = Atomic{Resps: resps}
 
-	log.Info("Starting server.")
-	log.Info("Listening on port: ", port)
+	// Catch signals already while loading, so that SIGTERM during a deploy
+	// doesn't kill us in the middle of a git clone.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	updateJumpFile(root)
+	// Listen on port and serve with our handler. Until the content is
+	// loaded, /_ready tells that we are not ready yet.
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Port),
+		Handler:           observed(cors(http.HandlerFunc(handler)), observeMetrics, logAccess),
+		ReadHeaderTimeout: cfg.ReadTimeout.Duration,
+		ReadTimeout:       cfg.ReadTimeout.Duration,
+		WriteTimeout:      cfg.WriteTimeout.Duration,
+		IdleTimeout:       cfg.IdleTimeout.Duration,
+	}
+	log.Info("Starting server.")
+	log.Info("Listening on port: ", cfg.Port)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalln(err)
+		}
+	}()
 
-	// Our request handler.
-	http.HandleFunc("/", handler)
+	// We'll parse and store the responses ahead of time.
+	for _, m := range mounts {
+		if err := m.load(); err != nil {
+			log.Fatalf("%s: could not load content: %s", m, err)
+		}
+		if ctx.Err() != nil {
+			log.Info("Interrupted while loading content.")
+			shutdown(srv, nil, cfg.ShutdownTimeout.Duration)
+			return
+		}
+	}
 
+	var watchers []chan notify.EventInfo
 	if watch {
-		events := make(chan notify.EventInfo, 5)
-		if err := notify.Watch(fmt.Sprintf("%s/...", root),
-			events,
-			notify.Create,
-			notify.Remove,
-			notify.Write,
-			notify.Rename); err != nil {
-			log.Warningln("notify.Watch:", err)
-		}
-		defer notify.Stop(events)
+		for _, m := range mounts {
+			events := make(chan notify.EventInfo, 5)
+			if err := notify.Watch(fmt.Sprintf("%s/...", m.root()),
+				events,
+				notify.Create,
+				notify.Remove,
+				notify.Write,
+				notify.Rename); err != nil {
+				log.Warningln("notify.Watch:", err)
+			}
+			watchers = append(watchers, events)
 
-		go func() {
-			for range events {
-				if err := reloadContent(); err != nil {
-					log.Warningln("Could not reload content: ", err)
+			go func(m *mount) {
+				for range events {
+					if err := m.reloadContent(); err != nil {
+						log.Warningln("Could not reload content: ", err)
+					}
 				}
-			}
-		}()
+			}(m)
+		}
 	}
 
-	// Listen on port and serve with our handler.
-	err = http.ListenAndServe(":"+port, nil)
+	<-ctx.Done()
+	// A second signal kills us the usual way.
+	stop()
+	log.Info("Shutting down.")
+	shutdown(srv, watchers, cfg.ShutdownTimeout.Duration)
+}
+
+// shutdown stops accepting requests, drains open connections, stops watching
+// for file changes and waits for running reloads, giving up after timeout.
+func shutdown(srv *http.Server, watchers []chan notify.EventInfo, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for _, events := range watchers {
+		notify.Stop(events)
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Warnln("Could not drain connections:", err)
+	}
+
+	reloaded := make(chan struct{})
+	go func() {
+		// Holding the locks also keeps new reloads from starting.
+		for _, m := range mounts {
+			m.reload.Lock()
+		}
+		close(reloaded)
+	}()
+	select {
+	case <-reloaded:
+		log.Info("Shut down.")
+	case <-ctx.Done():
+		log.Warnln("Gave up waiting for reloads to finish.")
+	}
+}
+
+// configCheck prints the effective configuration, or what is wrong with it,
+// and exits.
+func configCheck(cfg *Config, err error) {
+	if err == nil {
+		_, err = loadMounts(cfg)
+	}
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, redact.String(err.Error()))
+		os.Exit(1)
+	}
+	if err := cfg.print(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
+	os.Exit(0)
 }
 
-func updateJumpFile(root string) {
+// markdownDiff prints how the pages in the content directories dirs would
+// change with the CommonMark renderer, and exits.
+func markdownDiff(dirs []string) {
+	if len(dirs) == 0 {
+		usage()
+	}
+	changed := 0
+	for _, dir := range dirs {
+		n, err := pages.DiffMarkdown(os.Stdout, dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		changed += n
+	}
+	fmt.Fprintf(os.Stderr, "%d files would change\n", changed)
+	os.Exit(0)
+}
+
+// readJumpFile reads the redirects in the jumpfile at path, if it exists.
+func readJumpFile(path string) map[string]interface{} {
 	// If jumpfile exists.
-	if _, err := os.Stat(root + "/jumpfile.json"); err == nil {
-		buf, err := os.ReadFile(root + "/jumpfile.json")
+	if _, err := os.Stat(path); err == nil {
+		buf, err := os.ReadFile(path)
 		if err != nil {
 			log.Warningln("jumpfile readfile: unexpected error:", err)
 		}
@@ -227,10 +265,10 @@ func updateJumpFile(root string) {
 			log.Warningln("jumpfile unmarshal: unexpected error:", err)
 		}
 		log.Debugln(j)
-		jumpfile = j
-	} else {
-		log.Infoln("No jumpfile found")
+		return j
 	}
+	log.Infoln("No jumpfile found")
+	return nil
 }
 
 // Resp is the response we serve for file queries.
@@ -247,6 +285,19 @@ type Resp struct {
 	Expanded  bool            `json:"expanded"`   // Should the Nav-tree rooted in this node always be expanded one step when loaded?
 	Anchors   []anchor.Anchor `json:"anchors"`    // The list of anchors to headers in the body.
 	Nav       []*pages.Node   `json:"nav,omitempty"`
+
+	TOC        []*anchor.TOCEntry `json:"toc"`         // The anchors nested by level.
+	SidebarTOC []*anchor.TOCEntry `json:"sidebar_toc"` // The anchors of the sidebar nested by level.
+}
+
+// hasLang reports whether any of resps has a body in lang.
+func hasLang(resps map[string]*pages.Page, lang string) bool {
+	for _, r := range resps {
+		if _, ok := r.Bodies[lang]; ok {
+			return true
+		}
+	}
+	return false
 }
 
 func responseExistForLang(resp *pages.Page, lang string) bool {
@@ -271,29 +322,114 @@ func responseExistForLang(resp *pages.Page, lang string) bool {
 
 // handler parses and serves responses to our file queries.
 func handler(res http.ResponseWriter, req *http.Request) {
-	res.Header().Add("Access-Control-Allow-Origin", "*")
-	res.Header().Add("Access-Control-Allow-Methods", "*")
+	switch req.URL.Path {
+	case "/_health":
+		setRoute(res, routeInternal)
+		healthHandler(res, req)
+		return
+	case "/_ready":
+		setRoute(res, routeInternal)
+		readyHandler(res, req)
+		return
+	case "/metrics":
+		setRoute(res, routeInternal)
+		metricsHandler.ServeHTTP(res, req)
+		return
+	case "/_assets/highlight.css":
+		setRoute(res, routeAsset)
+		highlightCSSHandler(res, req)
+		return
+	}
+
+	m, query := findMount(req.Host, req.URL.Path)
+	if m == nil {
+		log.WithFields(log.Fields{"host": req.Host, "path": req.URL.Path}).Debug("No mount for path")
+		setRoute(res, routeNotFound)
+		writeError(res, http.StatusNotFound, apiError{
+			Code:    codeNotFound,
+			Message: "Page does not exist",
+			Path:    req.URL.Path,
+		})
+		return
+	}
+
+	responses := &m.responses
 
-	if v, ok := jumpfile[filepath.Clean(req.URL.Path)]; ok {
+	responses.Lock()
+	loaded := responses.Resps != nil
+	v, ok := responses.Jumpfile[query]
+	responses.Unlock()
+	if !loaded {
+		log.WithField("mount", m).Warn("Content is not loaded yet")
+		writeError(res, http.StatusServiceUnavailable, apiError{
+			Code:    codeNotLoaded,
+			Message: "Content is not loaded yet",
+			Path:    query,
+		})
+		return
+	}
+	if ok {
+		setRoute(res, routeRedirect)
 		newURL := v.(string)
 		http.Redirect(res, req, newURL, http.StatusSeeOther)
-		log.Infoln("Redirect: " + newURL)
 		return
 	}
 
-	if req.URL.Path == "/fuzzyfile" {
-		log.Info("Fuzzyfile")
+	if p, ok := strings.CutPrefix(query, assetsPath+"/"); ok {
+		setRoute(res, routeAsset)
+		assetHandler(res, req, m, p)
+		return
+	}
+	if query == brokenLinksPath {
+		setRoute(res, routeLinks)
+		brokenLinksHandler(res, req, m)
+		return
+	}
+	if query == "/fuzzyfile" {
+		setRoute(res, routeFuzzyfile)
+		opts := fuzz.Options{
+			BaseURL:     m.fuzzyBaseURL,
+			Lang:        req.URL.Query().Get("lang"),
+			DefaultLang: m.defaultLang,
+			AnchorLevel: m.fuzzyAnchors,
+		}
+		if level, err := strconv.Atoi(req.URL.Query().Get("anchors")); err == nil {
+			opts.AnchorLevel = min(max(level, 0), 6)
+		}
+		setLang(res, opts.Lang)
+		key := query + "\x00" + opts.Lang + "\x00" + strconv.Itoa(opts.AnchorLevel)
+		coding := acceptedCoding(res, req)
 		responses.Lock()
-		buf, err := json.Marshal(fuzz.NewFile(responses.Resps))
+		tag := etag(responses.Revision, key)
+		if m.notModified(res, req, codingTag(tag, coding), time.Time{}) {
+			responses.Unlock()
+			return
+		}
+		resps := responses.Resps
+		build := func() ([]byte, error) {
+			return json.Marshal(fuzz.NewFile(resps, opts))
+		}
+		var e *encoded
+		var err error
+		// The cache isn't bounded, so only the languages of the content are
+		// cached, lest requests for made up ones fill it.
+		if opts.Lang == "" || hasLang(resps, opts.Lang) {
+			e, err = responses.Cache.get(key, build)
+		} else {
+			e, err = newEncoded(build)
+		}
 		responses.Unlock()
 		if err != nil {
 			log.Warnf("handler: unexpected error: %#v\n", err)
-			res.WriteHeader(http.StatusInternalServerError)
+			writeError(res, http.StatusInternalServerError, apiError{
+				Code:    codeInternal,
+				Message: "Could not create the fuzzyfile",
+				Path:    query,
+			})
 			return
 		}
-		log.Debugf("Response: %#v\n", string(buf))
 		res.Header().Set("Content-Type", "application/json; charset=utf-8")
-		res.Write(buf)
+		e.write(res, coding, tag)
 		return
 	}
 	// NOTE: we're not checking the authenticity of any of these webhooks, but
@@ -301,21 +437,29 @@ func handler(res http.ResponseWriter, req *http.Request) {
 	// pulling that from either github or darkmode (using https so we can trust
 	// that) the worst someone could do is a DOS.
 	if req.Header.Get("X-Github-Event") == "push" {
-		log.Infoln("Push hook")
-		if err := getContent(); err != nil {
-			res.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		if err := reloadContent(); err != nil {
-			res.WriteHeader(http.StatusInternalServerError)
+		log.WithField("mount", m).Infoln("Push hook")
+		setRoute(res, routeWebhook)
+		if err := m.load(); err != nil {
+			log.WithField("mount", m).Warnln("Could not reload content: ", err)
+			writeError(res, http.StatusInternalServerError, apiError{
+				Code:    codeInternal,
+				Message: "Could not reload content",
+				Path:    query,
+			})
 			return
 		}
 		return
 	}
 	if req.Header.Get("X-Darkmode-Event") == "updated" {
-		log.Infoln("Darkmode hook")
-		if err := reloadContent(); err != nil {
-			res.WriteHeader(http.StatusInternalServerError)
+		log.WithField("mount", m).Infoln("Darkmode hook")
+		setRoute(res, routeWebhook)
+		if err := m.reloadContent(); err != nil {
+			log.WithField("mount", m).Warnln("Could not reload content: ", err)
+			writeError(res, http.StatusInternalServerError, apiError{
+				Code:    codeInternal,
+				Message: "Could not reload content",
+				Path:    query,
+			})
 			return
 		}
 		return
@@ -323,104 +467,147 @@ func handler(res http.ResponseWriter, req *http.Request) {
 
 	lang := req.URL.Query().Get("lang")
 	if lang == "" {
-		lang = getEnv("DEFAULT_LANG")
+		lang = m.defaultLang
 	}
+	setLang(res, lang)
 
-	// Requested URL. We extract the path.
-	query := req.URL.Path
-	log.WithField("query", query).Info("Received query")
+	if query == "/search" {
+		setRoute(res, routeSearch)
+		searchHandler(res, req, m, lang)
+		return
+	}
 
+	// Requested URL. We extract the path inside the mount.
 	clean := filepath.Clean(query)
-	log.WithField("clean", clean).Info("Sanitized path")
-	log.Println(rootDir(clean))
 	responses.Lock()
-	defer responses.Unlock()
 
 	r, ok := responses.Resps[clean]
 	if !ok {
-		log.WithField("page", clean).Warn("Page doesn't exist")
-		res.WriteHeader(http.StatusNotFound)
-		res.Write([]byte("Page does not exist"))
+		// Paths differing only in case, encoding or normalization are
+		// redirected to the canonical URL of the page.
+		if p, ok := responses.Canonical[suggest.Canonical(clean)]; ok {
+			responses.Unlock()
+			setRoute(res, routeRedirect)
+			http.Redirect(res, req, m.url(p, req.URL.RawQuery), http.StatusMovedPermanently)
+			return
+		}
+		log.WithFields(log.Fields{"mount": m, "page": clean}).Debug("Page doesn't exist")
+		notFoundTotal.WithLabelValues(m.String(), notFoundBucket(responses.Resps, clean)).Inc()
+		suggestions := suggestPaths(responses.Resps, clean)
+		ancestor := closestAncestor(responses.Resps, clean)
+		responses.Unlock()
+		if p, ok := suggest.Unambiguous(suggestions); ok {
+			setRoute(res, routeRedirect)
+			http.Redirect(res, req, m.url(p, req.URL.RawQuery), http.StatusSeeOther)
+			return
+		}
+		e := apiError{
+			Code:    codeNotFound,
+			Message: "Page does not exist",
+			Path:    clean,
+			Lang:    lang,
+		}
+		for _, c := range suggestions {
+			e.Suggestions = append(e.Suggestions, c.Path)
+		}
+		if len(e.Suggestions) == 0 && ancestor != "" {
+			e.Suggestions = []string{ancestor}
+		}
+		writeError(res, http.StatusNotFound, e)
 		return
 	}
 
 	// If the page does not hve complete information for a language, it can't create a response
 	if !responseExistForLang(r, lang) {
-		log.WithField("page", clean).Warn("Page doesn't exist for requested language")
-		res.WriteHeader(http.StatusNotFound)
-		res.Write([]byte("Page does not exist for the requested language"))
+		log.WithFields(log.Fields{"mount": m, "page": clean, "lang": lang}).Debug("Page doesn't exist for requested language")
+		langs := languages(r)
+		responses.Unlock()
+		writeError(res, http.StatusNotFound, apiError{
+			Code:      codeLangNotFound,
+			Message:   "Page does not exist for the requested language",
+			Path:      clean,
+			Lang:      lang,
+			Languages: langs,
+		})
+		return
+	}
+	// The response has the nav and sidebar of other pages too, so it is as
+	// new as the snapshot rather than the page.
+	modified := responses.Modified
+	coding := acceptedCoding(res, req)
+	tag := etag(responses.Revision, clean, lang)
+	if m.notModified(res, req, codingTag(tag, coding), modified) {
+		responses.Unlock()
 		return
 	}
+
+	// The response for a page and language never changes within a snapshot.
+	resps := responses.Resps
+	e, err := responses.Cache.get(clean+"\x00"+lang, func() ([]byte, error) {
+		return json.Marshal(newResp(resps, clean, lang))
+	})
+	responses.Unlock()
+	if err != nil {
+		log.Warnf("handler: unexpected error: %#v\n", err)
+		writeError(res, http.StatusInternalServerError, apiError{
+			Code:    codeInternal,
+			Message: "Could not create the response",
+			Path:    clean,
+			Lang:    lang,
+		})
+		return
+	}
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	e.write(res, coding, tag)
+}
+
+// newResp creates the response for the page at clean in lang.
+func newResp(resps map[string]*pages.Page, clean, lang string) Resp {
+	r := resps[clean]
+
 	// Sort the slugs
 	var slugs []string
-	for k := range responses.Resps {
+	for k := range resps {
 		slugs = append(slugs, k)
 	}
 	sort.Strings(slugs)
 
 	// Our web tree.
-	root := pages.NewNode("/", "/", responses.Resps["/"].Titles[lang])
+	root := pages.NewNode("/", "/", resps["/"].Titles[lang])
 
 	for _, slug := range slugs {
 		root.AddNode(
 			strings.FieldsFunc(clean, func(c rune) bool { return c == '/' }),
 			slug,
-			responses.Resps[slug].Titles[lang],
-			responses.Resps[slug].Image,
+			resps[slug].Titles[lang],
+			resps[slug].Image,
 			strings.FieldsFunc(slug, func(c rune) bool { return c == '/' }),
 			false,
-			responses.Resps[slug].Expanded,
-			responses.Resps[slug].Sort,
+			resps[slug].Expanded,
+			resps[slug].Sort,
 		)
 	}
 
 	resp := Resp{
-		URL:       clean,
-		Nav:       nil,
-		Title:     r.Titles[lang],
-		Body:      r.Bodies[lang],
-		Sidebar:   r.Sidebars[lang],
-		Slug:      r.Slug,
-		Image:     r.Image,
-		UpdatedAt: r.UpdatedAt[lang],
-		Message:   r.Message,
-		Sort:      r.Sort,
-		Expanded:  r.Expanded,
-		Anchors:   r.Anchors[lang],
+		URL:        clean,
+		Nav:        nil,
+		Title:      r.Titles[lang],
+		Body:       r.Bodies[lang],
+		Sidebar:    r.Sidebars[lang],
+		Slug:       r.Slug,
+		Image:      r.Image,
+		UpdatedAt:  r.UpdatedAt[lang],
+		Message:    r.Message,
+		Sort:       r.Sort,
+		Expanded:   r.Expanded,
+		Anchors:    r.Anchors[lang],
+		TOC:        r.TOCs[lang],
+		SidebarTOC: r.SidebarTOCs[lang],
 	}
 	if root.Num() != 1 {
 		resp.Nav = root.Nav
 	}
-
-	log.Info("Marshaling the response.")
-	buf, err := json.Marshal(resp)
-	if err != nil {
-		log.Warnf("handler: unexpected error: %#v\n", err)
-		res.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	log.Info("Serve the response.")
-	log.Debugf("Response: %#v\n", string(buf))
-	res.Header().Set("Content-Type", "application/json; charset=utf-8")
-	res.Write(buf)
-}
-
-func reloadContent() error {
-	isReception, err := getDarkmode()
-	if err != nil {
-		return fmt.Errorf("Could not get darkmode status: %w", err)
-	}
-	resps, err := pages.Load(isReception, getRoot())
-	if err != nil {
-		return fmt.Errorf("Could not load pages: %w", err)
-	}
-	responses.Lock()
-	responses.Resps = resps
-	responses.Unlock()
-
-	updateJumpFile(getRoot())
-
-	return nil
+	return resp
 }
 
 func rootDir(path string) string {
@@ -433,34 +620,3 @@ func rootDir(path string) string {
 	}
 	return path
 }
-
-var darkmode struct {
-	mu     sync.Mutex
-	result bool
-}
-
-func getDarkmode() (bool, error) {
-	darkmode.mu.Lock()
-	defer darkmode.mu.Unlock()
-
-	url := getEnv("DARKMODE_URL")
-	if url == "true" {
-		darkmode.result = true
-		return true, nil
-	}
-	if url == "false" {
-		darkmode.result = false
-		return false, nil
-	}
-
-	res, err := http.Get(url)
-	if err != nil {
-		return true, err
-	}
-	if err := json.NewDecoder(res.Body).Decode(&darkmode.result); err != nil {
-		return true, err
-	}
-	log.Info("Darkmode status: ", darkmode.result)
-
-	return darkmode.result, nil
-}